@@ -0,0 +1,171 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.27.3
+// source: api/proto/payment_admin.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	PaymentAdminService_ReconcilePayments_FullMethodName   = "/payment.PaymentAdminService/ReconcilePayments"
+	PaymentAdminService_ListCircuitBreakers_FullMethodName = "/payment.PaymentAdminService/ListCircuitBreakers"
+)
+
+// PaymentAdminServiceClient is the client API for PaymentAdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// PaymentAdminService holds operations for running the payment service rather
+// than for taking payments.
+type PaymentAdminServiceClient interface {
+	// ReconcilePayments compares our payments with the transactions the gateways
+	// report and returns the discrepancies found.
+	ReconcilePayments(ctx context.Context, in *ReconcilePaymentsRequest, opts ...grpc.CallOption) (*ReconcilePaymentsResponse, error)
+	// ListCircuitBreakers returns the state of the gateway circuit breakers.
+	ListCircuitBreakers(ctx context.Context, in *ListCircuitBreakersRequest, opts ...grpc.CallOption) (*ListCircuitBreakersResponse, error)
+}
+
+type paymentAdminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPaymentAdminServiceClient(cc grpc.ClientConnInterface) PaymentAdminServiceClient {
+	return &paymentAdminServiceClient{cc}
+}
+
+func (c *paymentAdminServiceClient) ReconcilePayments(ctx context.Context, in *ReconcilePaymentsRequest, opts ...grpc.CallOption) (*ReconcilePaymentsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReconcilePaymentsResponse)
+	err := c.cc.Invoke(ctx, PaymentAdminService_ReconcilePayments_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentAdminServiceClient) ListCircuitBreakers(ctx context.Context, in *ListCircuitBreakersRequest, opts ...grpc.CallOption) (*ListCircuitBreakersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListCircuitBreakersResponse)
+	err := c.cc.Invoke(ctx, PaymentAdminService_ListCircuitBreakers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PaymentAdminServiceServer is the server API for PaymentAdminService service.
+// All implementations must embed UnimplementedPaymentAdminServiceServer
+// for forward compatibility.
+//
+// PaymentAdminService holds operations for running the payment service rather
+// than for taking payments.
+type PaymentAdminServiceServer interface {
+	// ReconcilePayments compares our payments with the transactions the gateways
+	// report and returns the discrepancies found.
+	ReconcilePayments(context.Context, *ReconcilePaymentsRequest) (*ReconcilePaymentsResponse, error)
+	// ListCircuitBreakers returns the state of the gateway circuit breakers.
+	ListCircuitBreakers(context.Context, *ListCircuitBreakersRequest) (*ListCircuitBreakersResponse, error)
+	mustEmbedUnimplementedPaymentAdminServiceServer()
+}
+
+// UnimplementedPaymentAdminServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPaymentAdminServiceServer struct{}
+
+func (UnimplementedPaymentAdminServiceServer) ReconcilePayments(context.Context, *ReconcilePaymentsRequest) (*ReconcilePaymentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReconcilePayments not implemented")
+}
+func (UnimplementedPaymentAdminServiceServer) ListCircuitBreakers(context.Context, *ListCircuitBreakersRequest) (*ListCircuitBreakersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCircuitBreakers not implemented")
+}
+func (UnimplementedPaymentAdminServiceServer) mustEmbedUnimplementedPaymentAdminServiceServer() {}
+func (UnimplementedPaymentAdminServiceServer) testEmbeddedByValue()                             {}
+
+// UnsafePaymentAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PaymentAdminServiceServer will
+// result in compilation errors.
+type UnsafePaymentAdminServiceServer interface {
+	mustEmbedUnimplementedPaymentAdminServiceServer()
+}
+
+func RegisterPaymentAdminServiceServer(s grpc.ServiceRegistrar, srv PaymentAdminServiceServer) {
+	// If the following call pancis, it indicates UnimplementedPaymentAdminServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PaymentAdminService_ServiceDesc, srv)
+}
+
+func _PaymentAdminService_ReconcilePayments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReconcilePaymentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentAdminServiceServer).ReconcilePayments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentAdminService_ReconcilePayments_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentAdminServiceServer).ReconcilePayments(ctx, req.(*ReconcilePaymentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentAdminService_ListCircuitBreakers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCircuitBreakersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentAdminServiceServer).ListCircuitBreakers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentAdminService_ListCircuitBreakers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentAdminServiceServer).ListCircuitBreakers(ctx, req.(*ListCircuitBreakersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PaymentAdminService_ServiceDesc is the grpc.ServiceDesc for PaymentAdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PaymentAdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "payment.PaymentAdminService",
+	HandlerType: (*PaymentAdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReconcilePayments",
+			Handler:    _PaymentAdminService_ReconcilePayments_Handler,
+		},
+		{
+			MethodName: "ListCircuitBreakers",
+			Handler:    _PaymentAdminService_ListCircuitBreakers_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api/proto/payment_admin.proto",
+}
@@ -19,11 +19,14 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	PaymentService_ProcessPayment_FullMethodName   = "/payment.PaymentService/ProcessPayment"
-	PaymentService_RefundPayment_FullMethodName    = "/payment.PaymentService/RefundPayment"
-	PaymentService_GetPaymentStatus_FullMethodName = "/payment.PaymentService/GetPaymentStatus"
-	PaymentService_GetPaymentDetail_FullMethodName = "/payment.PaymentService/GetPaymentDetail"
-	PaymentService_ListPayments_FullMethodName     = "/payment.PaymentService/ListPayments"
+	PaymentService_ProcessPayment_FullMethodName     = "/payment.PaymentService/ProcessPayment"
+	PaymentService_RefundPayment_FullMethodName      = "/payment.PaymentService/RefundPayment"
+	PaymentService_GetPaymentStatus_FullMethodName   = "/payment.PaymentService/GetPaymentStatus"
+	PaymentService_GetPaymentDetail_FullMethodName   = "/payment.PaymentService/GetPaymentDetail"
+	PaymentService_ListPayments_FullMethodName       = "/payment.PaymentService/ListPayments"
+	PaymentService_ListRefunds_FullMethodName        = "/payment.PaymentService/ListRefunds"
+	PaymentService_CancelPayment_FullMethodName      = "/payment.PaymentService/CancelPayment"
+	PaymentService_WatchPaymentStatus_FullMethodName = "/payment.PaymentService/WatchPaymentStatus"
 )
 
 // PaymentServiceClient is the client API for PaymentService service.
@@ -35,6 +38,11 @@ type PaymentServiceClient interface {
 	GetPaymentStatus(ctx context.Context, in *GetPaymentStatusRequest, opts ...grpc.CallOption) (*GetPaymentStatusResponse, error)
 	GetPaymentDetail(ctx context.Context, in *GetPaymentDetailRequest, opts ...grpc.CallOption) (*GetPaymentDetailResponse, error)
 	ListPayments(ctx context.Context, in *ListPaymentsRequest, opts ...grpc.CallOption) (*ListPaymentsResponse, error)
+	ListRefunds(ctx context.Context, in *ListRefundsRequest, opts ...grpc.CallOption) (*ListRefundsResponse, error)
+	CancelPayment(ctx context.Context, in *CancelPaymentRequest, opts ...grpc.CallOption) (*CancelPaymentResponse, error)
+	// WatchPaymentStatus sends the current status of a payment, then every
+	// change, until the payment is settled or the client cancels.
+	WatchPaymentStatus(ctx context.Context, in *WatchPaymentStatusRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PaymentStatusUpdate], error)
 }
 
 type paymentServiceClient struct {
@@ -95,6 +103,45 @@ func (c *paymentServiceClient) ListPayments(ctx context.Context, in *ListPayment
 	return out, nil
 }
 
+func (c *paymentServiceClient) ListRefunds(ctx context.Context, in *ListRefundsRequest, opts ...grpc.CallOption) (*ListRefundsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRefundsResponse)
+	err := c.cc.Invoke(ctx, PaymentService_ListRefunds_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentServiceClient) CancelPayment(ctx context.Context, in *CancelPaymentRequest, opts ...grpc.CallOption) (*CancelPaymentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelPaymentResponse)
+	err := c.cc.Invoke(ctx, PaymentService_CancelPayment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentServiceClient) WatchPaymentStatus(ctx context.Context, in *WatchPaymentStatusRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PaymentStatusUpdate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PaymentService_ServiceDesc.Streams[0], PaymentService_WatchPaymentStatus_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchPaymentStatusRequest, PaymentStatusUpdate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PaymentService_WatchPaymentStatusClient = grpc.ServerStreamingClient[PaymentStatusUpdate]
+
 // PaymentServiceServer is the server API for PaymentService service.
 // All implementations must embed UnimplementedPaymentServiceServer
 // for forward compatibility.
@@ -104,6 +151,11 @@ type PaymentServiceServer interface {
 	GetPaymentStatus(context.Context, *GetPaymentStatusRequest) (*GetPaymentStatusResponse, error)
 	GetPaymentDetail(context.Context, *GetPaymentDetailRequest) (*GetPaymentDetailResponse, error)
 	ListPayments(context.Context, *ListPaymentsRequest) (*ListPaymentsResponse, error)
+	ListRefunds(context.Context, *ListRefundsRequest) (*ListRefundsResponse, error)
+	CancelPayment(context.Context, *CancelPaymentRequest) (*CancelPaymentResponse, error)
+	// WatchPaymentStatus sends the current status of a payment, then every
+	// change, until the payment is settled or the client cancels.
+	WatchPaymentStatus(*WatchPaymentStatusRequest, grpc.ServerStreamingServer[PaymentStatusUpdate]) error
 	mustEmbedUnimplementedPaymentServiceServer()
 }
 
@@ -129,6 +181,15 @@ func (UnimplementedPaymentServiceServer) GetPaymentDetail(context.Context, *GetP
 func (UnimplementedPaymentServiceServer) ListPayments(context.Context, *ListPaymentsRequest) (*ListPaymentsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListPayments not implemented")
 }
+func (UnimplementedPaymentServiceServer) ListRefunds(context.Context, *ListRefundsRequest) (*ListRefundsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRefunds not implemented")
+}
+func (UnimplementedPaymentServiceServer) CancelPayment(context.Context, *CancelPaymentRequest) (*CancelPaymentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelPayment not implemented")
+}
+func (UnimplementedPaymentServiceServer) WatchPaymentStatus(*WatchPaymentStatusRequest, grpc.ServerStreamingServer[PaymentStatusUpdate]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchPaymentStatus not implemented")
+}
 func (UnimplementedPaymentServiceServer) mustEmbedUnimplementedPaymentServiceServer() {}
 func (UnimplementedPaymentServiceServer) testEmbeddedByValue()                        {}
 
@@ -240,6 +301,53 @@ func _PaymentService_ListPayments_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _PaymentService_ListRefunds_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRefundsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentServiceServer).ListRefunds(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentService_ListRefunds_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentServiceServer).ListRefunds(ctx, req.(*ListRefundsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentService_CancelPayment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelPaymentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentServiceServer).CancelPayment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentService_CancelPayment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentServiceServer).CancelPayment(ctx, req.(*CancelPaymentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentService_WatchPaymentStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchPaymentStatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PaymentServiceServer).WatchPaymentStatus(m, &grpc.GenericServerStream[WatchPaymentStatusRequest, PaymentStatusUpdate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PaymentService_WatchPaymentStatusServer = grpc.ServerStreamingServer[PaymentStatusUpdate]
+
 // PaymentService_ServiceDesc is the grpc.ServiceDesc for PaymentService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -267,7 +375,21 @@ var PaymentService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListPayments",
 			Handler:    _PaymentService_ListPayments_Handler,
 		},
+		{
+			MethodName: "ListRefunds",
+			Handler:    _PaymentService_ListRefunds_Handler,
+		},
+		{
+			MethodName: "CancelPayment",
+			Handler:    _PaymentService_CancelPayment_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchPaymentStatus",
+			Handler:       _PaymentService_WatchPaymentStatus_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "api/proto/payment.proto",
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net"
 	"net/http"
@@ -9,9 +10,12 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	"github.com/stripe/stripe-go/v72/webhook"
 
 	"payment-service/api/proto"
+	"payment-service/internal/domain"
 	"payment-service/internal/infrastructure/db"
+	"payment-service/internal/infrastructure/eventpublisher"
 	"payment-service/internal/infrastructure/paymentgateway"
 	"payment-service/internal/infrastructure/repository"
 	grpcServer "payment-service/internal/interface/grpc"
@@ -36,13 +40,44 @@ func main() {
 	}
 	mongoClient := db.NewMongoClient(mongoURI)
 
+	// Migrate documents stored with earlier schemas
+	migrated, err := repository.MigratePaymentReferences(context.Background(), mongoClient)
+	if err != nil {
+		log.Fatalf("failed to migrate payment references: %v", err)
+	}
+	if migrated > 0 {
+		log.Printf("Migrated gateway references of %d payments", migrated)
+	}
+	migrated, err = repository.MigrateMoneyAmounts(context.Background(), mongoClient)
+	if err != nil {
+		log.Fatalf("failed to migrate money amounts: %v", err)
+	}
+	if migrated > 0 {
+		log.Printf("Migrated amounts of %d payments and refunds to minor units", migrated)
+	}
+	migrated, err = repository.MigrateLegacyStatuses(context.Background(), mongoClient)
+	if err != nil {
+		log.Fatalf("failed to migrate payment statuses: %v", err)
+	}
+	if migrated > 0 {
+		log.Printf("Migrated legacy statuses of %d payments", migrated)
+	}
+
 	// Initialize repository
 	paymentRepo := repository.NewMongoPaymentRepository(mongoClient)
-
-	// Initialize payment gateway clients
-	stripeClient := paymentgateway.NewStripeClient()
-	xenditClient := paymentgateway.NewXenditClient()
+	refundRepo := repository.NewMongoRefundRepository(mongoClient)
+	webhookEventRepo := repository.NewMongoWebhookEventRepository(mongoClient)
+	webhookSubscriptionRepo := repository.NewMongoWebhookSubscriptionRepository(mongoClient)
+	webhookDeliveryRepo := repository.NewMongoWebhookDeliveryRepository(mongoClient)
+	outboxRepo := repository.NewMongoOutboxRepository(mongoClient)
+	gatewayRouteRepo := repository.NewMongoGatewayRouteRepository(mongoClient)
+
+	// Register payment gateway clients
+	gateways := usecase.NewGatewayRegistry()
+	gateways.Register(domain.GatewayStripe, paymentgateway.NewStripeClient())
+	gateways.Register(domain.GatewayXendit, paymentgateway.NewXenditClient())
 	dokuClient := paymentgateway.NewDokuClient()
+	gateways.Register(domain.GatewayDoku, dokuClient)
 
 	// Initialize gRPC client for PaymentConfigService
 	grpcAddr := os.Getenv("PAYMENT_CONFIG_SERVICE_HOST")
@@ -70,17 +105,81 @@ func main() {
 		log.Fatalf("failed to parse GRPC_TIMEOUT: %v", err)
 	}
 
-	paymentConfigClient := paymentgateway.NewPaymentConfigClient(grpcConn, timeoutDuration)
+	configCacheTTL := time.Minute
+	if ttl := os.Getenv("PAYMENT_CONFIG_CACHE_TTL"); ttl != "" {
+		configCacheTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			log.Fatalf("failed to parse PAYMENT_CONFIG_CACHE_TTL: %v", err)
+		}
+	}
+
+	paymentConfigClient := paymentgateway.NewPaymentConfigClient(grpcConn, timeoutDuration, configCacheTTL, gatewayRouteRepo)
 
-	// Initialize use case
-	paymentUseCase := usecase.NewPaymentUseCase(stripeClient, xenditClient, dokuClient, paymentRepo, paymentConfigClient)
+	// Initialize use cases
+	merchantWebhookUseCase := usecase.NewMerchantWebhookUseCase(webhookSubscriptionRepo, webhookDeliveryRepo, &http.Client{Timeout: 30 * time.Second}, usecase.DefaultMerchantWebhookConfig)
+	gatewayBreakers := usecase.NewGatewayBreakers(usecase.DefaultCircuitBreakerConfig)
+	paymentUseCase := usecase.NewPaymentUseCase(gateways, paymentRepo, refundRepo, paymentConfigClient, merchantWebhookUseCase, gatewayBreakers)
+	reconciliationUseCase := usecase.NewReconciliationUseCase(gateways, paymentRepo, paymentUseCase)
 
-	// Initialize gRPC handler
+	// Initialize gRPC handlers
 	paymentHandler := grpcServer.NewPaymentHandler(paymentUseCase)
+	merchantWebhookHandler := grpcServer.NewMerchantWebhookHandler(merchantWebhookUseCase)
+	adminHandler := grpcServer.NewPaymentAdminHandler(reconciliationUseCase, gatewayBreakers)
 
 	// Set up gRPC server
 	grpcServer := grpc.NewServer()
 	proto.RegisterPaymentServiceServer(grpcServer, paymentHandler)
+	proto.RegisterMerchantWebhookServiceServer(grpcServer, merchantWebhookHandler)
+	proto.RegisterPaymentAdminServiceServer(grpcServer, adminHandler)
+
+	// Publish outbox events in the background
+	var publisher domain.EventPublisher
+	switch os.Getenv("EVENT_PUBLISHER") {
+	case "", "stdout":
+		publisher = eventpublisher.NewStdoutPublisher()
+	case "memory":
+		publisher = eventpublisher.NewMemoryPublisher()
+	default:
+		log.Fatalf("unsupported EVENT_PUBLISHER %q", os.Getenv("EVENT_PUBLISHER"))
+	}
+	outboxRelay := usecase.NewOutboxRelay(outboxRepo, publisher, 100)
+	go runEvery(context.Background(), time.Second, "outbox relay", func(ctx context.Context) error {
+		_, err := outboxRelay.RelayPending(ctx)
+		return err
+	})
+
+	// Expire overdue pending payments in the background
+	go runEvery(context.Background(), 30*time.Second, "payment expiry sweep", func(ctx context.Context) error {
+		_, err := paymentUseCase.ExpireOverduePayments(ctx, 100)
+		return err
+	})
+
+	// Resolve payments whose creation at the gateway was interrupted
+	go runEvery(context.Background(), time.Minute, "initiated payment recovery", func(ctx context.Context) error {
+		_, err := paymentUseCase.RecoverInitiatedPayments(ctx, 5*time.Minute, 100)
+		return err
+	})
+
+	// Send merchant webhooks in the background
+	go runEvery(context.Background(), 5*time.Second, "merchant webhook delivery", func(ctx context.Context) error {
+		_, err := merchantWebhookUseCase.DeliverDue(ctx)
+		return err
+	})
+
+	// Reconcile the last day of payments with the gateways in the background
+	reconciliationInterval := time.Hour
+	if interval := os.Getenv("RECONCILIATION_INTERVAL"); interval != "" {
+		reconciliationInterval, err = time.ParseDuration(interval)
+		if err != nil {
+			log.Fatalf("failed to parse RECONCILIATION_INTERVAL: %v", err)
+		}
+	}
+	reconciliationAutoHeal := os.Getenv("RECONCILIATION_AUTO_HEAL") == "true"
+	go runEvery(context.Background(), reconciliationInterval, "payment reconciliation", func(ctx context.Context) error {
+		now := time.Now()
+		_, err := reconciliationUseCase.ReconcileAll(ctx, now.Add(-24*time.Hour), now, reconciliationAutoHeal)
+		return err
+	})
 
 	// Start gRPC server
 	go func() {
@@ -95,9 +194,36 @@ func main() {
 	}()
 
 	// Set up REST server
-	restHandler := restServer.NewPaymentHandler(paymentUseCase)
+	xenditCallbackToken := os.Getenv("XENDIT_CALLBACK_TOKEN")
+	if xenditCallbackToken == "" {
+		log.Fatal("XENDIT_CALLBACK_TOKEN environment variable is not set")
+	}
+	stripeEndpointSecret := os.Getenv("STRIPE_WEBHOOK_SECRET")
+	if stripeEndpointSecret == "" {
+		log.Fatal("STRIPE_WEBHOOK_SECRET environment variable is not set")
+	}
+	stripeTolerance := webhook.DefaultTolerance
+	if tolerance := os.Getenv("STRIPE_WEBHOOK_TOLERANCE"); tolerance != "" {
+		stripeTolerance, err = time.ParseDuration(tolerance)
+		if err != nil {
+			log.Fatalf("failed to parse STRIPE_WEBHOOK_TOLERANCE: %v", err)
+		}
+	}
+	restHandler := restServer.NewPaymentHandler(paymentUseCase, usecase.NewWebhookInbox(webhookEventRepo), restServer.WebhookConfig{
+		XenditCallbackToken:  xenditCallbackToken,
+		StripeEndpointSecret: stripeEndpointSecret,
+		StripeTolerance:      stripeTolerance,
+		DokuVerifier:         dokuClient,
+	})
 	router := mux.NewRouter()
 	router.HandleFunc("/payments", restHandler.CreatePayment).Methods("POST")
+	router.HandleFunc("/webhooks/xendit/invoice", restHandler.XenditInvoiceCallback).Methods("POST")
+	router.HandleFunc("/webhooks/xendit/fva", restHandler.XenditFVACallback).Methods("POST")
+	router.HandleFunc("/webhooks/xendit/ewallet", restHandler.XenditEWalletCallback).Methods("POST")
+	router.HandleFunc("/webhooks/xendit/qr", restHandler.XenditQRCallback).Methods("POST")
+	router.HandleFunc("/webhooks/xendit/refund", restHandler.XenditRefundCallback).Methods("POST")
+	router.HandleFunc("/webhooks/stripe", restHandler.StripeWebhook).Methods("POST")
+	router.HandleFunc("/webhooks/doku", restHandler.DokuNotification).Methods("POST")
 
 	// Start REST server
 	httpServer := &http.Server{
@@ -111,3 +237,19 @@ func main() {
 		log.Fatalf("failed to start REST server: %v", err)
 	}
 }
+
+// runEvery calls task every interval until ctx is done, logging its errors.
+func runEvery(ctx context.Context, interval time.Duration, name string, task func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := task(ctx); err != nil {
+				log.Printf("%s failed: %v", name, err)
+			}
+		}
+	}
+}
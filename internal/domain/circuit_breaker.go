@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// CircuitState is the state of a gateway circuit breaker.
+type CircuitState string
+
+const (
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen rejects calls until the gateway has had time to recover.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a single call through to probe whether the gateway recovered.
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// CircuitBreakerStatus is the health of a gateway, or of one payment method at
+// a gateway, as its circuit breaker has observed it.
+type CircuitBreakerStatus struct {
+	Gateway string
+	// PaymentMethod is empty for the breaker covering every method of the gateway.
+	PaymentMethod       string
+	State               CircuitState
+	ConsecutiveFailures int
+	Successes           int64
+	Failures            int64
+	AverageLatency      time.Duration
+	LastError           string
+	LastFailureAt       time.Time
+	OpenedAt            time.Time
+}
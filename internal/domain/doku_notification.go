@@ -0,0 +1,27 @@
+package domain
+
+// DokuNotification is the payload DOKU posts when a Checkout, virtual account
+// or e-wallet transaction completes.
+type DokuNotification struct {
+	Service struct {
+		ID string `json:"id"`
+	} `json:"service"`
+	Acquirer struct {
+		ID string `json:"id"`
+	} `json:"acquirer"`
+	Channel struct {
+		ID string `json:"id"`
+	} `json:"channel"`
+	Order struct {
+		InvoiceNumber string `json:"invoice_number"`
+		Amount        int64  `json:"amount"`
+	} `json:"order"`
+	VirtualAccountInfo struct {
+		VirtualAccountNumber string `json:"virtual_account_number"`
+	} `json:"virtual_account_info"`
+	Transaction struct {
+		Status            string `json:"status"`
+		Date              string `json:"date"`
+		OriginalRequestID string `json:"original_request_id"`
+	} `json:"transaction"`
+}
@@ -0,0 +1,57 @@
+package domain
+
+import "errors"
+
+var (
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrDuplicatePayment is returned by repositories when a payment with the
+	// same idempotency key has already been stored.
+	ErrDuplicatePayment = errors.New("payment already exists")
+	// ErrIdempotencyConflict is returned when an idempotency key is reused
+	// with a request payload that differs from the original one.
+	ErrIdempotencyConflict = errors.New("idempotency key reused with a different request")
+
+	ErrUnsupportedGateway       = errors.New("unsupported payment gateway")
+	ErrUnsupportedPaymentMethod = errors.New("unsupported payment method")
+	// ErrRefundNotSupported is returned by gateways for payments they cannot refund.
+	ErrRefundNotSupported = errors.New("refund not supported")
+	// ErrExpiryNotSupported is returned by gateways for payments they cannot deactivate.
+	ErrExpiryNotSupported = errors.New("expiring payments at the gateway not supported")
+	// ErrCancelNotSupported is returned for payments their gateway cannot cancel.
+	ErrCancelNotSupported = errors.New("cancelling payments at the gateway not supported")
+	// ErrReconciliationNotSupported is returned for gateways that cannot list their transactions.
+	ErrReconciliationNotSupported = errors.New("reconciliation not supported for gateway")
+	// ErrPaymentInitiated is returned for a retried request whose payment is
+	// still being created at the gateway.
+	ErrPaymentInitiated = errors.New("payment is still being created at the gateway")
+	// ErrGatewayTransactionNotFound is returned when a gateway has no payment
+	// with the external ID looked up.
+	ErrGatewayTransactionNotFound = errors.New("payment not found at gateway")
+	// ErrTransactionLookupNotSupported is returned for payments whose gateway
+	// cannot look them up by external ID.
+	ErrTransactionLookupNotSupported = errors.New("looking up payments at the gateway not supported")
+	// ErrGatewayMismatch is returned for a notification about a payment from a
+	// gateway other than the one the payment was made through.
+	ErrGatewayMismatch = errors.New("notification from a gateway other than the payment's")
+	// ErrGatewayUnavailable is returned when calls to a gateway are held back
+	// by its open circuit breaker.
+	ErrGatewayUnavailable = errors.New("payment gateway unavailable")
+
+	ErrInvalidRefundAmount = errors.New("refund amount must be positive")
+	// ErrRefundExceedsCaptured is returned when a refund would take the total
+	// refunded above the amount captured for the payment.
+	ErrRefundExceedsCaptured = errors.New("refund exceeds the captured amount")
+	ErrRefundNotFound        = errors.New("refund not found")
+
+	// ErrDuplicateWebhookEvent is returned by repositories when a webhook with
+	// the same provider and webhook ID has already been stored.
+	ErrDuplicateWebhookEvent = errors.New("webhook event already received")
+	ErrWebhookEventNotFound  = errors.New("webhook event not found")
+	// ErrWebhookEventInProgress is returned for a delivery of a webhook event
+	// that another delivery is processing.
+	ErrWebhookEventInProgress = errors.New("webhook event is being processed")
+
+	ErrWebhookSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrWebhookDeliveryNotFound     = errors.New("webhook delivery not found")
+	ErrInvalidCallbackURL          = errors.New("invalid webhook callback URL")
+)
@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventType names a domain event published to other services.
+type EventType string
+
+const (
+	EventPaymentCreated   EventType = "PaymentCreated"
+	EventPaymentSucceeded EventType = "PaymentSucceeded"
+	EventPaymentFailed    EventType = "PaymentFailed"
+	EventPaymentExpired   EventType = "PaymentExpired"
+	EventPaymentCancelled EventType = "PaymentCancelled"
+	EventRefundIssued     EventType = "RefundIssued"
+	// EventRefundReverted is published when a refund that went through fails
+	// afterwards, giving its amount back to the payment.
+	EventRefundReverted EventType = "RefundReverted"
+)
+
+// paymentStatusEvents maps the statuses other services are told about to
+// their events. A payment only succeeds once, so PaymentSucceeded is published
+// when it is paid from pending or expired, not when it returns to paid.
+var paymentStatusEvents = map[PaymentStatus]EventType{
+	PaymentStatusPending:   EventPaymentCreated,
+	PaymentStatusFailed:    EventPaymentFailed,
+	PaymentStatusExpired:   EventPaymentExpired,
+	PaymentStatusCancelled: EventPaymentCancelled,
+}
+
+// PaymentStatusEvent returns the event published when a payment moves from
+// status from to status to, if there is one.
+func PaymentStatusEvent(from, to PaymentStatus) (EventType, bool) {
+	switch {
+	case to == PaymentStatusPaid && (from == PaymentStatusPending || from == PaymentStatusExpired):
+		return EventPaymentSucceeded, true
+	case from == PaymentStatusRefunded && (to == PaymentStatusPartiallyRefunded || to == PaymentStatusPaid),
+		from == PaymentStatusPartiallyRefunded && to == PaymentStatusPaid:
+		return EventRefundReverted, true
+	}
+	eventType, ok := paymentStatusEvents[to]
+	return eventType, ok
+}
+
+// OutboxEventStatus is the publishing state of an outbox event.
+type OutboxEventStatus string
+
+const (
+	OutboxEventStatusPending   OutboxEventStatus = "pending"
+	OutboxEventStatusPublished OutboxEventStatus = "published"
+)
+
+// OutboxEvent is a domain event stored with the state change it describes, and
+// published afterwards. Payload is the JSON event body.
+type OutboxEvent struct {
+	EventID       string
+	Type          EventType
+	PaymentID     string
+	Payload       string
+	Status        OutboxEventStatus
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	PublishedAt   time.Time
+}
+
+// paymentEventPayload is the body of every payment and refund event.
+type paymentEventPayload struct {
+	EventID             string    `json:"event_id"`
+	Type                EventType `json:"type"`
+	PaymentID           string    `json:"payment_id"`
+	UserID              string    `json:"user_id"`
+	Agent               string    `json:"agent"`
+	InvoiceNumber       string    `json:"invoice_number"`
+	Status              string    `json:"status"`
+	Gateway             string    `json:"gateway"`
+	PaymentMethod       string    `json:"payment_method"`
+	Currency            string    `json:"currency"`
+	AmountMinor         int64     `json:"amount_minor"`
+	RefundedAmountMinor int64     `json:"refunded_amount_minor"`
+	RefundID            string    `json:"refund_id,omitempty"`
+	RefundAmountMinor   int64     `json:"refund_amount_minor,omitempty"`
+	OccurredAt          time.Time `json:"occurred_at"`
+}
+
+// NewPaymentEvent returns an event of eventType describing payment as it is now.
+func NewPaymentEvent(eventType EventType, payment *Payment) OutboxEvent {
+	return newOutboxEvent(eventType, payment, nil)
+}
+
+// NewRefundIssuedEvent returns the event describing a refund of payment that went through.
+func NewRefundIssuedEvent(payment *Payment, refund *Refund) OutboxEvent {
+	return newOutboxEvent(EventRefundIssued, payment, refund)
+}
+
+func newOutboxEvent(eventType EventType, payment *Payment, refund *Refund) OutboxEvent {
+	now := time.Now()
+	event := OutboxEvent{
+		EventID:       uuid.New().String(),
+		Type:          eventType,
+		PaymentID:     payment.PaymentID,
+		Status:        OutboxEventStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	payload := paymentEventPayload{
+		EventID:             event.EventID,
+		Type:                eventType,
+		PaymentID:           payment.PaymentID,
+		UserID:              payment.UserID,
+		Agent:               payment.Agent,
+		InvoiceNumber:       payment.InvoiceNumber,
+		Status:              string(payment.Status),
+		Gateway:             payment.Gateway,
+		PaymentMethod:       payment.PaymentMethod,
+		Currency:            payment.Amount.Currency,
+		AmountMinor:         payment.Amount.MinorUnits,
+		RefundedAmountMinor: payment.RefundedAmount.MinorUnits,
+		OccurredAt:          now,
+	}
+	if refund != nil {
+		payload.RefundID = refund.RefundID
+		payload.RefundAmountMinor = refund.Amount.MinorUnits
+	}
+	// Marshalling a struct of plain fields cannot fail.
+	encoded, _ := json.Marshal(payload)
+	event.Payload = string(encoded)
+	return event
+}
+
+// EventPublisher delivers outbox events to other services. Publish may be
+// called more than once for an event, so consumers must dedupe on EventID.
+type EventPublisher interface {
+	Publish(ctx context.Context, event OutboxEvent) error
+}
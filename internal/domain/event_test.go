@@ -0,0 +1,36 @@
+package domain
+
+import "testing"
+
+func TestPaymentStatusEvent(t *testing.T) {
+	tests := []struct {
+		from PaymentStatus
+		to   PaymentStatus
+		want EventType
+	}{
+		{from: "", to: PaymentStatusInitiated},
+		{from: PaymentStatusInitiated, to: PaymentStatusPending, want: EventPaymentCreated},
+		{from: PaymentStatusInitiated, to: PaymentStatusFailed, want: EventPaymentFailed},
+		{from: PaymentStatusPending, to: PaymentStatusPaid, want: EventPaymentSucceeded},
+		{from: PaymentStatusExpired, to: PaymentStatusPaid, want: EventPaymentSucceeded},
+		{from: PaymentStatusPending, to: PaymentStatusExpired, want: EventPaymentExpired},
+		{from: PaymentStatusPending, to: PaymentStatusCancelled, want: EventPaymentCancelled},
+		// Refunds are announced by RefundIssued, written with the refund.
+		{from: PaymentStatusPaid, to: PaymentStatusPartiallyRefunded},
+		{from: PaymentStatusPaid, to: PaymentStatusRefunded},
+		{from: PaymentStatusPartiallyRefunded, to: PaymentStatusRefunded},
+		// A payment returning to paid was paid before and must not succeed twice.
+		{from: PaymentStatusPartiallyRefunded, to: PaymentStatusPaid, want: EventRefundReverted},
+		{from: PaymentStatusRefunded, to: PaymentStatusPaid, want: EventRefundReverted},
+		{from: PaymentStatusRefunded, to: PaymentStatusPartiallyRefunded, want: EventRefundReverted},
+		{from: PaymentStatusPaid, to: PaymentStatusDisputed},
+		{from: PaymentStatusDisputed, to: PaymentStatusPaid},
+	}
+
+	for _, tt := range tests {
+		got, ok := PaymentStatusEvent(tt.from, tt.to)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("PaymentStatusEvent(%q, %q) = %q, %t, want %q", tt.from, tt.to, got, ok, tt.want)
+		}
+	}
+}
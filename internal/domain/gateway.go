@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Gateway codes used by the payment config service and stored on payments.
+const (
+	GatewayXendit = "XENDIT"
+	GatewayDoku   = "DOKU"
+	GatewayStripe = "STRIPE"
+)
+
+// PaymentGateway is implemented by every payment gateway adapter.
+type PaymentGateway interface {
+	// SupportedMethods lists the payment methods ProcessPayment accepts.
+	SupportedMethods() []string
+	// ProcessPayment creates the payment at the gateway for payment.PaymentMethod
+	// and returns the gateway's ID for it.
+	ProcessPayment(ctx context.Context, payment *Payment) (string, error)
+	RefundPayment(ctx context.Context, payment *Payment, amount Money) (*GatewayRefund, error)
+}
+
+// GatewayRefund is a refund created at a gateway. Most gateways settle refunds
+// afterwards, so Status is usually pending and the outcome arrives by webhook.
+type GatewayRefund struct {
+	GatewayRefundID string
+	Status          RefundStatus
+}
+
+// PaymentExpirer is implemented by gateways that can stop a pending payment
+// from being paid, e.g. by closing its virtual account, once it has expired.
+type PaymentExpirer interface {
+	ExpirePayment(ctx context.Context, payment *Payment) error
+}
+
+// PaymentCanceller is implemented by gateways that can cancel a pending
+// payment, so that it can no longer be paid.
+type PaymentCanceller interface {
+	CancelPayment(ctx context.Context, payment *Payment) error
+}
+
+// GatewayError is an error of a gateway call, classified by whether the call
+// may succeed if tried again or at another gateway. Failures to connect, rate
+// limiting and server errors are retryable; rejected requests and declined
+// payments are not. A failure after which the gateway may still have served
+// the request, such as a timeout, is ambiguous rather than retryable.
+type GatewayError struct {
+	Gateway   string
+	Retryable bool
+	// Ambiguous is set when the gateway may have created the payment despite
+	// the error, so it must not be created at another gateway as well.
+	Ambiguous bool
+	// GatewayWide is set for failures of the gateway as a whole rather than of
+	// one of its payment methods: failing to reach it, timeouts, rate limiting
+	// and server errors.
+	GatewayWide bool
+	Err         error
+}
+
+func (e *GatewayError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *GatewayError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryableGatewayError reports whether err is a GatewayError that is
+// retryable. Errors no gateway classified are not.
+func IsRetryableGatewayError(err error) bool {
+	var gatewayErr *GatewayError
+	return errors.As(err, &gatewayErr) && gatewayErr.Retryable
+}
+
+// IsAmbiguousGatewayError reports whether err is a GatewayError after which
+// the gateway may have served the request.
+func IsAmbiguousGatewayError(err error) bool {
+	var gatewayErr *GatewayError
+	return errors.As(err, &gatewayErr) && gatewayErr.Ambiguous
+}
+
+// IsGatewayWideError reports whether err is a GatewayError of the gateway as
+// a whole, not only of the payment method called.
+func IsGatewayWideError(err error) bool {
+	var gatewayErr *GatewayError
+	return errors.As(err, &gatewayErr) && gatewayErr.GatewayWide
+}
+
+// PaymentAttempt is one try at creating a payment at a gateway.
+type PaymentAttempt struct {
+	Gateway   string
+	Succeeded bool
+	Error     string
+	// Retryable is set for a failure that led to trying the next gateway.
+	Retryable   bool
+	AttemptedAt time.Time
+	Latency     time.Duration
+}
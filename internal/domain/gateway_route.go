@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// GatewayRoute is the ordered list of gateways the payment config service
+// returned for a payment method, kept as the last known good routing should
+// the service become unavailable.
+type GatewayRoute struct {
+	PaymentMethod string
+	Gateways      []string
+	FetchedAt     time.Time
+}
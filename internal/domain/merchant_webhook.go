@@ -0,0 +1,56 @@
+package domain
+
+import "time"
+
+// WebhookSubscription is an agent's callback URL for payment status changes.
+// Each agent has at most one.
+type WebhookSubscription struct {
+	SubscriptionID string
+	Agent          string
+	CallbackURL    string
+	// Secret signs every delivery so the merchant can tell it came from us.
+	Secret    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WebhookDeliveryStatus is the state of a webhook we send to a merchant.
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "succeeded"
+	// WebhookDeliveryStatusDeadLetter is a delivery that failed every attempt;
+	// it is only sent again when redelivered by hand.
+	WebhookDeliveryStatusDeadLetter WebhookDeliveryStatus = "dead_letter"
+)
+
+// EventPaymentStatusChanged is the event type of merchant webhooks sent when a
+// payment changes status.
+const EventPaymentStatusChanged = "payment.status_changed"
+
+// WebhookDelivery is one notification to a merchant, retried until the merchant
+// accepts it or it is dead-lettered.
+type WebhookDelivery struct {
+	DeliveryID       string
+	SubscriptionID   string
+	Agent            string
+	PaymentID        string
+	EventType        string
+	Payload          string
+	Status           WebhookDeliveryStatus
+	Attempts         int
+	LastError        string
+	LastResponseCode int
+	NextAttemptAt    time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	DeliveredAt      time.Time
+}
+
+// WebhookDeliveryFilter selects deliveries; empty fields match everything.
+type WebhookDeliveryFilter struct {
+	Agent     string
+	PaymentID string
+	Status    WebhookDeliveryStatus
+}
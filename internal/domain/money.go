@@ -0,0 +1,157 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DefaultCurrency is assumed when a request does not name a currency; Xendit
+// and DOKU settle in rupiah when none is sent.
+const DefaultCurrency = "IDR"
+
+var (
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	ErrCurrencyMismatch    = errors.New("currency mismatch")
+	// ErrPrecisionLoss is returned when an amount cannot be expressed exactly
+	// with fewer decimals, e.g. 10.50 USD as a whole-dollar amount.
+	ErrPrecisionLoss = errors.New("amount cannot be represented without losing precision")
+)
+
+// currencyExponents holds the number of minor-unit decimals of each supported
+// ISO 4217 currency. IDR is listed by ISO with two decimals, but the sen is not
+// in circulation and the Indonesian gateways reject fractional rupiah, so it is
+// handled as a zero-decimal currency.
+var currencyExponents = map[string]int{
+	"IDR": 0,
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"CLP": 0,
+	"ISK": 0,
+	"PYG": 0,
+	"UGX": 0,
+	"XAF": 0,
+	"XOF": 0,
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"SGD": 2,
+	"MYR": 2,
+	"PHP": 2,
+	"THB": 2,
+	"AUD": 2,
+	"NZD": 2,
+	"CAD": 2,
+	"CHF": 2,
+	"CNY": 2,
+	"HKD": 2,
+	"TWD": 2,
+	"INR": 2,
+	"SEK": 2,
+	"NOK": 2,
+	"DKK": 2,
+	"AED": 2,
+	"SAR": 2,
+	"BHD": 3,
+	"JOD": 3,
+	"KWD": 3,
+	"OMR": 3,
+	"TND": 3,
+}
+
+// CurrencyExponent returns the number of minor-unit decimals of currency.
+func CurrencyExponent(currency string) (int, error) {
+	exponent, ok := currencyExponents[strings.ToUpper(currency)]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnsupportedCurrency, currency)
+	}
+	return exponent, nil
+}
+
+// Money is an exact amount in the minor unit of its currency, e.g. cents for
+// USD and whole rupiah for IDR.
+type Money struct {
+	MinorUnits int64
+	Currency   string
+}
+
+// NewMoney returns minorUnits of currency, checking that the currency is supported.
+func NewMoney(minorUnits int64, currency string) (Money, error) {
+	currency = strings.ToUpper(currency)
+	if _, err := CurrencyExponent(currency); err != nil {
+		return Money{}, err
+	}
+	return Money{MinorUnits: minorUnits, Currency: currency}, nil
+}
+
+// MoneyFromMajor converts a decimal amount in major units, e.g. 19.99 USD, to
+// Money, rounding to the nearest minor unit. It exists for clients still
+// sending floating-point amounts.
+func MoneyFromMajor(amount float64, currency string) (Money, error) {
+	currency = strings.ToUpper(currency)
+	exponent, err := CurrencyExponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{MinorUnits: int64(math.Round(amount * math.Pow10(exponent))), Currency: currency}, nil
+}
+
+// Major returns the amount in major units for gateway APIs that take decimals.
+// It is exact for every amount a float64 can hold to the cent.
+func (m Money) Major() float64 {
+	exponent, _ := CurrencyExponent(m.Currency)
+	return float64(m.MinorUnits) / math.Pow10(exponent)
+}
+
+// MinorUnitsWithExponent expresses the amount with a given number of decimals,
+// for gateways whose currency exponents differ from ours.
+func (m Money) MinorUnitsWithExponent(exponent int) (int64, error) {
+	own, err := CurrencyExponent(m.Currency)
+	if err != nil {
+		return 0, err
+	}
+	if exponent >= own {
+		return m.MinorUnits * int64(math.Pow10(exponent-own)), nil
+	}
+	scale := int64(math.Pow10(own - exponent))
+	if m.MinorUnits%scale != 0 {
+		return 0, fmt.Errorf("%w: %s with %d decimals", ErrPrecisionLoss, m, exponent)
+	}
+	return m.MinorUnits / scale, nil
+}
+
+func (m Money) IsZero() bool {
+	return m.MinorUnits == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.MinorUnits < 0
+}
+
+// Add returns m + other; both must be in the same currency.
+func (m Money) Add(other Money) (Money, error) {
+	if !strings.EqualFold(m.Currency, other.Currency) {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	return Money{MinorUnits: m.MinorUnits + other.MinorUnits, Currency: m.Currency}, nil
+}
+
+// Sub returns m - other; both must be in the same currency.
+func (m Money) Sub(other Money) (Money, error) {
+	return m.Add(Money{MinorUnits: -other.MinorUnits, Currency: other.Currency})
+}
+
+// Mul returns m multiplied by a quantity.
+func (m Money) Mul(quantity int64) Money {
+	return Money{MinorUnits: m.MinorUnits * quantity, Currency: m.Currency}
+}
+
+func (m Money) String() string {
+	exponent, err := CurrencyExponent(m.Currency)
+	if err != nil {
+		return fmt.Sprintf("%d (minor units) %s", m.MinorUnits, m.Currency)
+	}
+	return fmt.Sprintf("%.*f %s", exponent, m.Major(), m.Currency)
+}
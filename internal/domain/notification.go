@@ -0,0 +1,24 @@
+package domain
+
+// PaymentNotification is a gateway's report, received by webhook, that a
+// payment reached a new status.
+type PaymentNotification struct {
+	Gateway string
+	// ExternalID and GatewayReference identify the payment; the payment is looked
+	// up by ExternalID first, as not every stored payment has a reference.
+	ExternalID       string
+	GatewayReference string
+	Status           PaymentStatus
+	// QrPaymentID is the ID of the payment made on a QR code, against which QR
+	// refunds are issued.
+	QrPaymentID string
+}
+
+// RefundNotification is a gateway's report, received by webhook, of the
+// outcome of a refund.
+type RefundNotification struct {
+	Gateway         string
+	GatewayRefundID string
+	Status          RefundStatus
+	FailureReason   string
+}
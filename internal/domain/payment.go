@@ -1,66 +1,116 @@
 package domain
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
 
 type Item struct {
 	ItemName string
 	Quantity int
-	Price    float64
+	Price    Money
 }
 
 type Payment struct {
-	PaymentID             string
-	UserID                string
-	Amount                float64
-	Gateway               string
-	Currency              string
-	Status                string
-	CreatedAt             time.Time
-	UpdatedAt             time.Time
+	// PaymentID is our own ID for the payment, returned to clients.
+	PaymentID string
+	// ExternalID is the ID we sent to the gateway (Xendit external_id/reference_id,
+	// DOKU invoice_number); gateway callbacks refer to the payment by it.
+	ExternalID string
+	// GatewayReference is the gateway's own ID for the charge, invoice, VA or QR code.
+	GatewayReference string
+	UserID           string
+	Amount           Money
+	// Tax, Shipping and Discount are optional order-level lines; with the
+	// items they must add up to Amount.
+	Tax            Money
+	Shipping       Money
+	Discount       Money
+	RefundedAmount Money
+	Gateway        string
+	Status         PaymentStatus
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	// ExpiresAt is when the gateway stops accepting the payment; it is set by the
+	// gateway adapter for methods that expire, and zero otherwise.
+	ExpiresAt time.Time
+	// CancellationReason is the merchant's reason for cancelling the payment.
+	CancellationReason string
+	// Attempts lists the tries at creating the payment at each gateway, in order.
+	Attempts []PaymentAttempt
+	// NeedsReconciliation is set on an initiated payment whose gateway cannot
+	// tell whether it was created. It is resolved by the gateway's webhook or by
+	// reconciliation rather than failed, as the payer may still pay it.
+	NeedsReconciliation   bool
 	PaymentMethod         string
 	PhoneNumber           string
 	EwalletCheckoutMethod string
 	QrType                string
 	QrCallbackURL         string
 	QrString              string
+	QrPaymentID           string
 	InvoiceNumber         string
 	Agent                 string
 	Items                 []Item
+	IdempotencyKey        string
+	RequestHash           string
 }
 
-type QRCallbackRequest struct {
-	Event      string                          `json:"event"`
-	APIVersion string                          `json:"api_version"`
-	BusinessID string                          `json:"business_id"`
-	Created    time.Time                       `json:"created"`
-	Data       XenditWebhookRequestPaymentData `json:"data"`
+// RefundStatus returns the status of a captured payment given how much of it
+// has been refunded.
+func (p *Payment) RefundStatus() PaymentStatus {
+	switch {
+	case p.RefundedAmount.MinorUnits <= 0:
+		return PaymentStatusPaid
+	case p.RefundedAmount.MinorUnits < p.Amount.MinorUnits:
+		return PaymentStatusPartiallyRefunded
+	default:
+		return PaymentStatusRefunded
+	}
 }
 
-// PaymentData struct for the 'data' field
-type XenditWebhookRequestPaymentData struct {
-	WebHookID     string                            `json:webhook_id`
-	ID            string                            `json:"id"`
-	BusinessID    string                            `json:"business_id"`
-	Currency      string                            `json:"currency"`
-	Amount        int                               `json:"amount"`
-	Status        string                            `json:"status"`
-	Created       time.Time                         `json:"created"`
-	QRID          string                            `json:"qr_id"`
-	QRString      string                            `json:"qr_string"`
-	ReferenceID   string                            `json:"reference_id"`
-	Type          string                            `json:"type"`
-	ChannelCode   string                            `json:"channel_code"`
-	ExpiresAt     time.Time                         `json:"expires_at"`
-	Description   string                            `json:"description"`
-	Basket        string                            `json:"basket"`
-	Metadata      string                            `json:"metadata"`
-	PaymentDetail XenditWebhookRequestPaymentDetail `json:"payment_detail"`
+// DefaultIdempotencyKey derives the idempotency key used when the client does
+// not send one: an agent never reuses an invoice number for another payment.
+func (p *Payment) DefaultIdempotencyKey() string {
+	return p.Agent + ":" + p.InvoiceNumber
 }
 
-// PaymentDetail struct for the 'payment_detail' field within PaymentData
-type XenditWebhookRequestPaymentDetail struct {
-	ReceiptID      string `json:"receipt_id"`
-	Source         string `json:"source"`
-	Name           string `json:"name"`
-	AccountDetails string `json:"account_details"`
+// Fingerprint hashes the client-supplied fields of a payment request, so a
+// retry can be told apart from a different request reusing the same key.
+func (p *Payment) Fingerprint() string {
+	request := struct {
+		UserID                string
+		Amount                Money
+		Tax                   Money
+		Shipping              Money
+		Discount              Money
+		PaymentMethod         string
+		PhoneNumber           string
+		EwalletCheckoutMethod string
+		QrType                string
+		QrCallbackURL         string
+		InvoiceNumber         string
+		Agent                 string
+		Items                 []Item
+	}{
+		UserID:                p.UserID,
+		Amount:                p.Amount,
+		Tax:                   p.Tax,
+		Shipping:              p.Shipping,
+		Discount:              p.Discount,
+		PaymentMethod:         p.PaymentMethod,
+		PhoneNumber:           p.PhoneNumber,
+		EwalletCheckoutMethod: p.EwalletCheckoutMethod,
+		QrType:                p.QrType,
+		QrCallbackURL:         p.QrCallbackURL,
+		InvoiceNumber:         p.InvoiceNumber,
+		Agent:                 p.Agent,
+		Items:                 p.Items,
+	}
+	// Marshalling a struct of plain fields cannot fail.
+	encoded, _ := json.Marshal(request)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
 }
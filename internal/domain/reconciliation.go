@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// GatewayTransaction is a payment as a gateway's transaction report records it.
+type GatewayTransaction struct {
+	Gateway          string
+	ExternalID       string
+	GatewayReference string
+	Amount           Money
+	Status           PaymentStatus
+	CreatedAt        time.Time
+	// ExpiresAt is when the payment stops being payable, zero if the gateway did not say.
+	ExpiresAt time.Time
+}
+
+// TransactionLister is implemented by gateways that can report the payments
+// created in a period, so that they can be reconciled with ours.
+type TransactionLister interface {
+	// ListTransactions returns the payments created from from up to, but not
+	// including, to.
+	ListTransactions(ctx context.Context, from, to time.Time) ([]GatewayTransaction, error)
+}
+
+// TransactionFinder is implemented by gateways that can look up a payment by
+// the external ID we created it with, to resolve payments whose creation was
+// interrupted.
+type TransactionFinder interface {
+	// FindTransaction returns ErrGatewayTransactionNotFound if the gateway has
+	// no payment with payment.ExternalID.
+	FindTransaction(ctx context.Context, payment *Payment) (*GatewayTransaction, error)
+}
+
+// DiscrepancyType is the kind of drift found between a gateway and our records.
+type DiscrepancyType string
+
+const (
+	// DiscrepancyMissingLocally is a gateway transaction without a payment.
+	DiscrepancyMissingLocally DiscrepancyType = "missing_locally"
+	// DiscrepancyMissingRemotely is a paid payment the gateway has no transaction for.
+	DiscrepancyMissingRemotely DiscrepancyType = "missing_remotely"
+	DiscrepancyAmountMismatch  DiscrepancyType = "amount_mismatch"
+	DiscrepancyStatusMismatch  DiscrepancyType = "status_mismatch"
+)
+
+// Discrepancy is one difference between a gateway transaction and the payment
+// matched to it by external ID. Fields of the side that is missing are zero.
+type Discrepancy struct {
+	Type         DiscrepancyType
+	Gateway      string
+	ExternalID   string
+	PaymentID    string
+	LocalAmount  Money
+	RemoteAmount Money
+	LocalStatus  PaymentStatus
+	RemoteStatus PaymentStatus
+	// Healed is set when the payment was corrected to match the gateway.
+	Healed    bool
+	HealError string
+}
+
+// ReconciliationReport lists the discrepancies found for one gateway over a period.
+type ReconciliationReport struct {
+	Gateway       string
+	From          time.Time
+	To            time.Time
+	Matched       int
+	Discrepancies []Discrepancy
+	GeneratedAt   time.Time
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// RefundStatus is the state of a single refund in the refund ledger.
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusSucceeded RefundStatus = "succeeded"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+// Refund is one refund issued against a payment. A payment may have several.
+type Refund struct {
+	RefundID        string
+	PaymentID       string
+	Amount          Money
+	Reason          string
+	Gateway         string
+	GatewayRefundID string
+	Status          RefundStatus
+	FailureReason   string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
@@ -1,19 +1,104 @@
 // internal/domain/repository.go
 package domain
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type PaymentRepository interface {
 	Save(ctx context.Context, payment *Payment) error
+	// Submit moves an initiated payment to pending, storing the gateway, gateway
+	// reference, QR string and expiry the gateway gave it, and its attempts.
+	Submit(ctx context.Context, payment *Payment) error
+	// Reject moves an initiated payment no gateway accepted to failed, storing its attempts.
+	Reject(ctx context.Context, payment *Payment) error
+	// RecordAttempts stores the attempts of an initiated payment and the gateway it is, or was last, tried at.
+	RecordAttempts(ctx context.Context, payment *Payment) error
 	FindByID(ctx context.Context, paymentID string) (*Payment, error)
+	FindByIdempotencyKey(ctx context.Context, key string) (*Payment, error)
+	FindByExternalID(ctx context.Context, externalID string) (*Payment, error)
+	FindByGatewayReference(ctx context.Context, gatewayReference string) (*Payment, error)
 	FindByUserID(ctx context.Context, userID string, page, pageSize int) ([]Payment, int, error)
-	UpdateStatus(ctx context.Context, paymentID, status string) error
+	UpdateStatus(ctx context.Context, paymentID string, status PaymentStatus) error
+	// Cancel moves a payment to PaymentStatusCancelled, recording the reason.
+	Cancel(ctx context.Context, paymentID, reason string) error
+	UpdateQrPaymentID(ctx context.Context, paymentID, qrPaymentID string) error
+	// FindExpired returns up to limit pending payments whose expiry is before now.
+	FindExpired(ctx context.Context, now time.Time, limit int) ([]Payment, error)
+	// FindInitiated returns up to limit payments, oldest first, that were
+	// initiated before before and never submitted, leaving out those flagged for
+	// reconciliation.
+	FindInitiated(ctx context.Context, before time.Time, limit int) ([]Payment, error)
+	// FlagForReconciliation marks an initiated payment as needing reconciliation.
+	FlagForReconciliation(ctx context.Context, paymentID string) error
+	// FindByGatewayCreatedBetween returns the payments made through gateway that
+	// were created from from up to, but not including, to.
+	FindByGatewayCreatedBetween(ctx context.Context, gateway string, from, to time.Time) ([]Payment, error)
+	// ReserveRefund adds amount to the payment's refunded amount unless that would
+	// exceed the payment amount, and returns the new refunded amount. It returns
+	// a *StatusTransitionError for a payment that is not paid or partially refunded.
+	ReserveRefund(ctx context.Context, paymentID string, amount Money) (Money, error)
+	// ReleaseRefund gives back an amount reserved for a refund that did not go through.
+	ReleaseRefund(ctx context.Context, paymentID string, amount Money) error
+	// UpdateRefundStatus moves a payment to the status its stored refunded
+	// amount calls for, as given by Payment.RefundStatus, and returns it.
+	UpdateRefundStatus(ctx context.Context, paymentID string) (*Payment, error)
+	// CompleteRefund records refund as succeeded and updates the status of its
+	// payment as UpdateRefundStatus does, together, and returns the payment.
+	CompleteRefund(ctx context.Context, refund *Refund) (*Payment, error)
 }
 
-type PaymentGateway interface {
-	ProcessPayment(ctx context.Context, payment *Payment) (string, error)
-	RefundPayment(ctx context.Context, paymentID string, amount float64) (string, error)
-	ChargeEWallet(ctx context.Context, payment *Payment) (string, error)
-	CreateVirtualAccount(ctx context.Context, payment *Payment) (string, error)
-	CreateQRCode(ctx context.Context, payment *Payment) (string, error)
+type RefundRepository interface {
+	Save(ctx context.Context, refund *Refund) error
+	Update(ctx context.Context, refund *Refund) error
+	FindByPaymentID(ctx context.Context, paymentID string) ([]Refund, error)
+	FindByGatewayRefundID(ctx context.Context, gateway, gatewayRefundID string) (*Refund, error)
+}
+
+type WebhookEventRepository interface {
+	Save(ctx context.Context, event *WebhookEvent) error
+	Find(ctx context.Context, provider, webhookID string) (*WebhookEvent, error)
+	// Claim marks an event that failed, or whose processing lease ran out, as
+	// processing until now plus lease, counts the attempt and returns the event.
+	// It returns ErrWebhookEventInProgress for an event it cannot claim.
+	Claim(ctx context.Context, provider, webhookID string, now time.Time, lease time.Duration) (*WebhookEvent, error)
+	// Update stores the processing outcome of an event.
+	Update(ctx context.Context, event *WebhookEvent) error
+}
+
+type WebhookSubscriptionRepository interface {
+	// Upsert stores subscription as the agent's only subscription.
+	Upsert(ctx context.Context, subscription *WebhookSubscription) error
+	FindByAgent(ctx context.Context, agent string) (*WebhookSubscription, error)
+	FindByID(ctx context.Context, subscriptionID string) (*WebhookSubscription, error)
+}
+
+type WebhookDeliveryRepository interface {
+	Save(ctx context.Context, delivery *WebhookDelivery) error
+	Update(ctx context.Context, delivery *WebhookDelivery) error
+	FindByID(ctx context.Context, deliveryID string) (*WebhookDelivery, error)
+	Find(ctx context.Context, filter WebhookDeliveryFilter, page, pageSize int) ([]WebhookDelivery, int, error)
+	// ClaimDue returns up to limit pending deliveries due at now, oldest first,
+	// and postpones their next attempt by lease so that no other instance sends
+	// them at the same time.
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, error)
+}
+
+// OutboxRepository reads the events written alongside payment and refund changes.
+type OutboxRepository interface {
+	// ClaimPending returns up to limit pending events due at now, oldest first,
+	// and postpones their next attempt by lease so that no other relay publishes
+	// them at the same time.
+	ClaimPending(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxEvent, error)
+	MarkPublished(ctx context.Context, eventID string) error
+	// MarkFailed records a failed publish and when to try again.
+	MarkFailed(ctx context.Context, eventID string, cause error, nextAttemptAt time.Time) error
+}
+
+// GatewayRouteRepository keeps the last routing the payment config service
+// returned for each payment method.
+type GatewayRouteRepository interface {
+	Upsert(ctx context.Context, route GatewayRoute) error
+	FindAll(ctx context.Context) ([]GatewayRoute, error)
 }
@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// PaymentStatus is the lifecycle state of a payment.
+type PaymentStatus string
+
+const (
+	// PaymentStatusInitiated is a payment recorded before it is created at the
+	// gateway. It becomes pending once the gateway accepted it.
+	PaymentStatusInitiated         PaymentStatus = "initiated"
+	PaymentStatusPending           PaymentStatus = "pending"
+	PaymentStatusPaid              PaymentStatus = "paid"
+	PaymentStatusFailed            PaymentStatus = "failed"
+	PaymentStatusExpired           PaymentStatus = "expired"
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentStatusRefunded          PaymentStatus = "refunded"
+	// PaymentStatusDisputed is a payment the payer has disputed with their bank.
+	PaymentStatusDisputed PaymentStatus = "disputed"
+	// PaymentStatusCancelled is a pending payment abandoned by the merchant.
+	PaymentStatusCancelled PaymentStatus = "cancelled"
+)
+
+var ErrInvalidStatusTransition = errors.New("invalid payment status transition")
+
+// StatusTransitionError reports an attempt to move a payment between two
+// statuses that are not connected in the state machine.
+type StatusTransitionError struct {
+	From PaymentStatus
+	To   PaymentStatus
+}
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("invalid payment status transition from %q to %q", e.From, e.To)
+}
+
+func (e *StatusTransitionError) Unwrap() error {
+	return ErrInvalidStatusTransition
+}
+
+// statusTransitions lists, for every status, the statuses it may move to.
+// Statuses without an entry are terminal.
+var statusTransitions = map[PaymentStatus][]PaymentStatus{
+	PaymentStatusInitiated: {PaymentStatusPending, PaymentStatusFailed},
+	PaymentStatusPending:   {PaymentStatusPaid, PaymentStatusExpired, PaymentStatusFailed, PaymentStatusCancelled},
+	// Not every gateway can deactivate a payment we expired, so one may still be
+	// paid afterwards; the money has been taken and must be accounted for.
+	PaymentStatusExpired:           {PaymentStatusPaid},
+	PaymentStatusPaid:              {PaymentStatusPartiallyRefunded, PaymentStatusRefunded, PaymentStatusDisputed},
+	PaymentStatusPartiallyRefunded: {PaymentStatusPartiallyRefunded, PaymentStatusRefunded, PaymentStatusPaid, PaymentStatusDisputed},
+	// A refund the gateway accepted may still fail later, which gives the
+	// amount back to the payment.
+	PaymentStatusRefunded: {PaymentStatusPartiallyRefunded, PaymentStatusPaid},
+	// A dispute ends with the payment kept, or with its amount returned to the payer.
+	PaymentStatusDisputed: {PaymentStatusPaid, PaymentStatusRefunded},
+}
+
+// CanTransitionTo reports whether a payment in status s may move to next.
+func (s PaymentStatus) CanTransitionTo(next PaymentStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionTo returns next if the move is allowed, or a *StatusTransitionError otherwise.
+func (s PaymentStatus) TransitionTo(next PaymentStatus) (PaymentStatus, error) {
+	if !s.CanTransitionTo(next) {
+		return s, &StatusTransitionError{From: s, To: next}
+	}
+	return next, nil
+}
+
+// IsSettlement reports whether s is an outcome of a pending payment: paid,
+// failed or expired.
+func (s PaymentStatus) IsSettlement() bool {
+	return s == PaymentStatusPaid || s == PaymentStatusFailed || s == PaymentStatusExpired
+}
+
+// IsTerminal reports whether no further transitions are possible from s.
+func (s PaymentStatus) IsTerminal() bool {
+	return len(statusTransitions[s]) == 0
+}
+
+// StatusesTransitioningTo returns every status from which next can be reached.
+// Repositories use it to make status updates conditional on the stored state.
+func StatusesTransitioningTo(next PaymentStatus) []PaymentStatus {
+	from := []PaymentStatus{}
+	for status, targets := range statusTransitions {
+		for _, target := range targets {
+			if target == next {
+				from = append(from, status)
+				break
+			}
+		}
+	}
+	return from
+}
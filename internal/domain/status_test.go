@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"errors"
+	"sort"
+	"testing"
+)
+
+var allStatuses = []PaymentStatus{
+	PaymentStatusInitiated,
+	PaymentStatusPending,
+	PaymentStatusPaid,
+	PaymentStatusFailed,
+	PaymentStatusExpired,
+	PaymentStatusPartiallyRefunded,
+	PaymentStatusRefunded,
+	PaymentStatusDisputed,
+	PaymentStatusCancelled,
+}
+
+type statusTransition struct {
+	from PaymentStatus
+	to   PaymentStatus
+}
+
+// allowedTransitions is every transition of the state machine. All others are refused.
+var allowedTransitions = map[statusTransition]bool{
+	{PaymentStatusInitiated, PaymentStatusPending}:                   true,
+	{PaymentStatusInitiated, PaymentStatusFailed}:                    true,
+	{PaymentStatusPending, PaymentStatusPaid}:                        true,
+	{PaymentStatusPending, PaymentStatusExpired}:                     true,
+	{PaymentStatusPending, PaymentStatusFailed}:                      true,
+	{PaymentStatusPending, PaymentStatusCancelled}:                   true,
+	{PaymentStatusExpired, PaymentStatusPaid}:                        true,
+	{PaymentStatusPaid, PaymentStatusPartiallyRefunded}:              true,
+	{PaymentStatusPaid, PaymentStatusRefunded}:                       true,
+	{PaymentStatusPaid, PaymentStatusDisputed}:                       true,
+	{PaymentStatusPartiallyRefunded, PaymentStatusPartiallyRefunded}: true,
+	{PaymentStatusPartiallyRefunded, PaymentStatusRefunded}:          true,
+	{PaymentStatusPartiallyRefunded, PaymentStatusPaid}:              true,
+	{PaymentStatusPartiallyRefunded, PaymentStatusDisputed}:          true,
+	{PaymentStatusRefunded, PaymentStatusPartiallyRefunded}:          true,
+	{PaymentStatusRefunded, PaymentStatusPaid}:                       true,
+	{PaymentStatusDisputed, PaymentStatusPaid}:                       true,
+	{PaymentStatusDisputed, PaymentStatusRefunded}:                   true,
+}
+
+func TestPaymentStatusTransitions(t *testing.T) {
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			want := allowedTransitions[statusTransition{from, to}]
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("%s.CanTransitionTo(%s) = %t, want %t", from, to, got, want)
+			}
+
+			next, err := from.TransitionTo(to)
+			if want {
+				if err != nil || next != to {
+					t.Errorf("%s.TransitionTo(%s) = %s, %v, want %s", from, to, next, err, to)
+				}
+				continue
+			}
+			var transitionErr *StatusTransitionError
+			if !errors.As(err, &transitionErr) || !errors.Is(err, ErrInvalidStatusTransition) {
+				t.Errorf("%s.TransitionTo(%s) error = %v, want a *StatusTransitionError", from, to, err)
+				continue
+			}
+			if next != from || transitionErr.From != from || transitionErr.To != to {
+				t.Errorf("%s.TransitionTo(%s) = %s, %+v, want %s unchanged", from, to, next, transitionErr, from)
+			}
+		}
+	}
+}
+
+func TestPaymentStatusTransitionsFromUnknownStatus(t *testing.T) {
+	// Statuses written by older versions of the service are not in the state
+	// machine, and must be migrated before they can change.
+	legacy := PaymentStatus("SETTLED")
+	for _, to := range allStatuses {
+		if legacy.CanTransitionTo(to) {
+			t.Errorf("%s.CanTransitionTo(%s) = true, want false", legacy, to)
+		}
+	}
+	if !legacy.IsTerminal() {
+		t.Errorf("%s.IsTerminal() = false, want true", legacy)
+	}
+}
+
+func TestPaymentStatusIsTerminal(t *testing.T) {
+	terminal := map[PaymentStatus]bool{
+		PaymentStatusFailed:    true,
+		PaymentStatusCancelled: true,
+	}
+	for _, status := range allStatuses {
+		if got := status.IsTerminal(); got != terminal[status] {
+			t.Errorf("%s.IsTerminal() = %t, want %t", status, got, terminal[status])
+		}
+	}
+}
+
+func TestPaymentStatusIsSettlement(t *testing.T) {
+	settlements := map[PaymentStatus]bool{
+		PaymentStatusPaid:    true,
+		PaymentStatusFailed:  true,
+		PaymentStatusExpired: true,
+	}
+	for _, status := range allStatuses {
+		if got := status.IsSettlement(); got != settlements[status] {
+			t.Errorf("%s.IsSettlement() = %t, want %t", status, got, settlements[status])
+		}
+	}
+}
+
+func TestStatusesTransitioningTo(t *testing.T) {
+	for _, to := range allStatuses {
+		var want []string
+		for _, from := range allStatuses {
+			if allowedTransitions[statusTransition{from, to}] {
+				want = append(want, string(from))
+			}
+		}
+		var got []string
+		for _, from := range StatusesTransitioningTo(to) {
+			got = append(got, string(from))
+		}
+		sort.Strings(want)
+		sort.Strings(got)
+		if len(got) != len(want) {
+			t.Errorf("StatusesTransitioningTo(%s) = %v, want %v", to, got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("StatusesTransitioningTo(%s) = %v, want %v", to, got, want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldViolation describes why one field of a request is invalid.
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// ValidationError collects every field violation found in a request, so the
+// client can fix them all at once.
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+func (e *ValidationError) Error() string {
+	descriptions := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		descriptions[i] = violation.Field + ": " + violation.Description
+	}
+	return "invalid payment request: " + strings.Join(descriptions, "; ")
+}
+
+// Add records a violation of field.
+func (e *ValidationError) Add(field, format string, args ...interface{}) {
+	e.Violations = append(e.Violations, FieldViolation{Field: field, Description: fmt.Sprintf(format, args...)})
+}
+
+// ErrOrNil returns e if any violation was recorded, and nil otherwise.
+func (e *ValidationError) ErrOrNil() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,42 @@
+package domain
+
+import "time"
+
+// WebhookEventStatus is the processing state of a received webhook.
+type WebhookEventStatus string
+
+const (
+	// WebhookEventStatusReceived is the status events were stored with before
+	// they were claimed for processing; none are stored with it any more.
+	WebhookEventStatusReceived WebhookEventStatus = "received"
+	// WebhookEventStatusProcessing is an event being applied by one instance of
+	// the service, until its LeaseUntil.
+	WebhookEventStatusProcessing WebhookEventStatus = "processing"
+	WebhookEventStatusProcessed  WebhookEventStatus = "processed"
+	WebhookEventStatusFailed     WebhookEventStatus = "failed"
+)
+
+// WebhookEvent is a webhook as received from a payment gateway, kept so that
+// redeliveries can be recognised and so that every notification can be
+// inspected later.
+type WebhookEvent struct {
+	// Provider is the code of the gateway that sent the webhook.
+	Provider string
+	// WebhookID is the provider's ID for the event, which stays the same across
+	// redeliveries.
+	WebhookID string
+	EventType string
+	Headers   map[string]string
+	Body      string
+	Status    WebhookEventStatus
+	// Result is the outcome of processing, and Error the reason it last failed.
+	Result   string
+	Error    string
+	Attempts int
+	// LeaseUntil is when a processing event may be claimed by another
+	// delivery, in case the instance processing it stopped.
+	LeaseUntil  time.Time
+	ReceivedAt  time.Time
+	ProcessedAt time.Time
+	UpdatedAt   time.Time
+}
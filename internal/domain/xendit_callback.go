@@ -0,0 +1,109 @@
+package domain
+
+import "time"
+
+// Payloads of the callbacks Xendit sends for each product. Amounts are in major
+// units, as everywhere in the Xendit API.
+
+// XenditInvoiceCallback is sent when an invoice is paid, settled or expires.
+type XenditInvoiceCallback struct {
+	ID             string    `json:"id"`
+	ExternalID     string    `json:"external_id"`
+	UserID         string    `json:"user_id"`
+	Status         string    `json:"status"`
+	Amount         float64   `json:"amount"`
+	PaidAmount     float64   `json:"paid_amount"`
+	Currency       string    `json:"currency"`
+	PaymentMethod  string    `json:"payment_method"`
+	PaymentChannel string    `json:"payment_channel"`
+	PaidAt         time.Time `json:"paid_at"`
+}
+
+// XenditFVAPaymentCallback is sent when a fixed virtual account receives a payment.
+type XenditFVAPaymentCallback struct {
+	ID                       string    `json:"id"`
+	PaymentID                string    `json:"payment_id"`
+	CallbackVirtualAccountID string    `json:"callback_virtual_account_id"`
+	ExternalID               string    `json:"external_id"`
+	BankCode                 string    `json:"bank_code"`
+	AccountNumber            string    `json:"account_number"`
+	MerchantCode             string    `json:"merchant_code"`
+	Amount                   float64   `json:"amount"`
+	TransactionTimestamp     time.Time `json:"transaction_timestamp"`
+}
+
+// XenditEWalletChargeCallback is sent when an e-wallet charge succeeds, fails or is voided.
+type XenditEWalletChargeCallback struct {
+	Event      string              `json:"event"`
+	BusinessID string              `json:"business_id"`
+	Created    time.Time           `json:"created"`
+	Data       XenditEWalletCharge `json:"data"`
+}
+
+type XenditEWalletCharge struct {
+	ID            string  `json:"id"`
+	ReferenceID   string  `json:"reference_id"`
+	Status        string  `json:"status"`
+	Currency      string  `json:"currency"`
+	ChargeAmount  float64 `json:"charge_amount"`
+	CaptureAmount float64 `json:"capture_amount"`
+	ChannelCode   string  `json:"channel_code"`
+	FailureCode   string  `json:"failure_code"`
+}
+
+// XenditRefundCallback is sent when a refund succeeds or fails.
+type XenditRefundCallback struct {
+	Event      string       `json:"event"`
+	BusinessID string       `json:"business_id"`
+	Created    time.Time    `json:"created"`
+	Data       XenditRefund `json:"data"`
+}
+
+type XenditRefund struct {
+	ID          string  `json:"id"`
+	PaymentID   string  `json:"payment_id"`
+	InvoiceID   string  `json:"invoice_id"`
+	ReferenceID string  `json:"reference_id"`
+	Status      string  `json:"status"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	FailureCode string  `json:"failure_code"`
+}
+
+// QRCallbackRequest is sent when a QR code is paid.
+type QRCallbackRequest struct {
+	Event      string                          `json:"event"`
+	APIVersion string                          `json:"api_version"`
+	BusinessID string                          `json:"business_id"`
+	Created    time.Time                       `json:"created"`
+	Data       XenditWebhookRequestPaymentData `json:"data"`
+}
+
+// PaymentData struct for the 'data' field
+type XenditWebhookRequestPaymentData struct {
+	WebHookID     string                            `json:"webhook_id"`
+	ID            string                            `json:"id"`
+	BusinessID    string                            `json:"business_id"`
+	Currency      string                            `json:"currency"`
+	Amount        int                               `json:"amount"`
+	Status        string                            `json:"status"`
+	Created       time.Time                         `json:"created"`
+	QRID          string                            `json:"qr_id"`
+	QRString      string                            `json:"qr_string"`
+	ReferenceID   string                            `json:"reference_id"`
+	Type          string                            `json:"type"`
+	ChannelCode   string                            `json:"channel_code"`
+	ExpiresAt     time.Time                         `json:"expires_at"`
+	Description   string                            `json:"description"`
+	Basket        string                            `json:"basket"`
+	Metadata      string                            `json:"metadata"`
+	PaymentDetail XenditWebhookRequestPaymentDetail `json:"payment_detail"`
+}
+
+// PaymentDetail struct for the 'payment_detail' field within PaymentData
+type XenditWebhookRequestPaymentDetail struct {
+	ReceiptID      string `json:"receipt_id"`
+	Source         string `json:"source"`
+	Name           string `json:"name"`
+	AccountDetails string `json:"account_details"`
+}
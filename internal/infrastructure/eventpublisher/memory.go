@@ -0,0 +1,31 @@
+package eventpublisher
+
+import (
+	"context"
+	"payment-service/internal/domain"
+	"sync"
+)
+
+// MemoryPublisher keeps published events in memory, for tests and local runs.
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []domain.OutboxEvent
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, event domain.OutboxEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+// Events returns the events published so far, oldest first.
+func (p *MemoryPublisher) Events() []domain.OutboxEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]domain.OutboxEvent(nil), p.events...)
+}
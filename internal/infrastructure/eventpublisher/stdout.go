@@ -0,0 +1,32 @@
+package eventpublisher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"payment-service/internal/domain"
+	"sync"
+)
+
+// WriterPublisher writes each event's payload as a line of JSON.
+type WriterPublisher struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewStdoutPublisher returns a publisher writing events to standard output.
+func NewStdoutPublisher() *WriterPublisher {
+	return NewWriterPublisher(os.Stdout)
+}
+
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+func (p *WriterPublisher) Publish(ctx context.Context, event domain.OutboxEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := fmt.Fprintln(p.w, event.Payload)
+	return err
+}
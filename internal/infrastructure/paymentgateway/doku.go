@@ -1,42 +1,406 @@
 package paymentgateway
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
 	"os"
 	"payment-service/internal/domain"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	dokuDefaultBaseURL = "https://api.doku.com"
+	dokuCheckoutPath   = "/checkout/v1/payment"
+	// dokuTimestampLayout is the ISO 8601 UTC format DOKU expects in Request-Timestamp.
+	dokuTimestampLayout = "2006-01-02T15:04:05Z"
+	// dokuCheckoutDueMinutes and dokuVAExpiryMinutes bound how long a DOKU payment stays payable.
+	dokuCheckoutDueMinutes = 60
+	dokuVAExpiryMinutes    = 24 * 60
+	// dokuNotificationTolerance is how far the Request-Timestamp of a
+	// notification may be from our clock, limiting replays of a captured one.
+	dokuNotificationTolerance = 5 * time.Minute
+)
+
+// dokuVAPaths maps our bank payment methods to DOKU's per-bank VA endpoints.
+var dokuVAPaths = map[string]string{
+	"BCA":     "/bca-virtual-account/v2/payment-code",
+	"BNI":     "/bni-virtual-account/v2/payment-code",
+	"BRI":     "/bri-virtual-account/v2/payment-code",
+	"MANDIRI": "/mandiri-virtual-account/v2/payment-code",
+}
+
+// dokuEWalletChannels maps our e-wallet payment methods to DOKU Checkout channels.
+var dokuEWalletChannels = map[string]string{
+	"OVO":       "EMONEY_OVO",
+	"DANA":      "EMONEY_DANA",
+	"LINKAJA":   "EMONEY_LINKAJA",
+	"SHOPEEPAY": "EMONEY_SHOPEE_PAY",
+}
+
+var (
+	ErrDokuUnauthorized   = errors.New("doku: unauthorized")
+	ErrDokuInvalidRequest = errors.New("doku: invalid request")
+	ErrDokuNotFound       = errors.New("doku: not found")
+	ErrDokuUnavailable    = errors.New("doku: service unavailable")
+	// ErrDokuInvalidSignature is returned for notifications not signed with our secret key.
+	ErrDokuInvalidSignature = errors.New("doku: invalid notification signature")
+	// ErrDokuStaleNotification is returned for notifications whose Request-Timestamp
+	// is missing or outside dokuNotificationTolerance.
+	ErrDokuStaleNotification = errors.New("doku: notification timestamp outside tolerance")
 )
 
+// DokuError is a non-2xx response from the DOKU API.
+type DokuError struct {
+	StatusCode int
+	Code       string
+	Type       string
+	Message    string
+}
+
+func (e *DokuError) Error() string {
+	return fmt.Sprintf("doku: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Unwrap classifies the response so callers can match on the ErrDoku* sentinels.
+func (e *DokuError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized, e.StatusCode == http.StatusForbidden:
+		return ErrDokuUnauthorized
+	case e.StatusCode == http.StatusNotFound:
+		return ErrDokuNotFound
+	case e.StatusCode == http.StatusTooManyRequests, e.StatusCode >= http.StatusInternalServerError:
+		return ErrDokuUnavailable
+	default:
+		return ErrDokuInvalidRequest
+	}
+}
+
 type DokuClient struct {
-	apiKey string
+	clientID   string
+	secretKey  string
+	baseURL    string
+	httpClient *http.Client
 }
 
 func NewDokuClient() *DokuClient {
+	baseURL := os.Getenv("DOKU_BASE_URL")
+	if baseURL == "" {
+		baseURL = dokuDefaultBaseURL
+	}
+	return NewDokuClientWithConfig(os.Getenv("DOKU_CLIENT_ID"), os.Getenv("DOKU_SECRET_KEY"), baseURL, &http.Client{Timeout: 30 * time.Second})
+}
+
+// NewDokuClientWithConfig builds a client against an explicit base URL and HTTP
+// client, e.g. the DOKU sandbox or a local httptest server.
+func NewDokuClientWithConfig(clientID, secretKey, baseURL string, httpClient *http.Client) *DokuClient {
 	return &DokuClient{
-		apiKey: os.Getenv("Doku_API_KEY"),
+		clientID:   clientID,
+		secretKey:  secretKey,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+type dokuOrder struct {
+	InvoiceNumber string         `json:"invoice_number"`
+	Amount        int64          `json:"amount"`
+	Currency      string         `json:"currency,omitempty"`
+	LineItems     []dokuLineItem `json:"line_items,omitempty"`
+}
+
+type dokuLineItem struct {
+	Name     string `json:"name"`
+	Price    int64  `json:"price"`
+	Quantity int    `json:"quantity"`
+}
+
+type dokuCustomer struct {
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Phone string `json:"phone,omitempty"`
+}
+
+type dokuCheckoutRequest struct {
+	Order   dokuOrder `json:"order"`
+	Payment struct {
+		PaymentDueDate     int      `json:"payment_due_date"`
+		PaymentMethodTypes []string `json:"payment_method_types,omitempty"`
+	} `json:"payment"`
+	Customer dokuCustomer `json:"customer"`
+}
+
+type dokuCheckoutResponse struct {
+	Response struct {
+		Payment struct {
+			TokenID     string `json:"token_id"`
+			URL         string `json:"url"`
+			ExpiredDate string `json:"expired_date"`
+		} `json:"payment"`
+	} `json:"response"`
+}
+
+type dokuVARequest struct {
+	Order              dokuOrder `json:"order"`
+	VirtualAccountInfo struct {
+		BillingType    string `json:"billing_type"`
+		ExpiredTime    int    `json:"expired_time"`
+		ReusableStatus bool   `json:"reusable_status"`
+	} `json:"virtual_account_info"`
+	Customer dokuCustomer `json:"customer"`
+}
+
+type dokuVAResponse struct {
+	VirtualAccountInfo struct {
+		VirtualAccountNumber string `json:"virtual_account_number"`
+		HowToPayPage         string `json:"how_to_pay_page"`
+		ExpiredDate          string `json:"expired_date"`
+	} `json:"virtual_account_info"`
+}
+
+type dokuErrorResponse struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+func (dc *DokuClient) SupportedMethods() []string {
+	methods := []string{"QR", "DEFAULT"}
+	for method := range dokuEWalletChannels {
+		methods = append(methods, method)
+	}
+	for method := range dokuVAPaths {
+		methods = append(methods, method)
+	}
+	return methods
+}
+
+// ProcessPayment creates the payment and classifies the error of a failed call
+// as a *domain.GatewayError.
+func (dc *DokuClient) ProcessPayment(ctx context.Context, payment *domain.Payment) (string, error) {
+	reference, err := dc.createPayment(ctx, payment)
+	if err != nil {
+		return "", dokuGatewayError(err)
+	}
+	return reference, nil
+}
+
+func (dc *DokuClient) createPayment(ctx context.Context, payment *domain.Payment) (string, error) {
+	if _, ok := dokuEWalletChannels[payment.PaymentMethod]; ok {
+		return dc.ChargeEWallet(ctx, payment)
+	}
+	if _, ok := dokuVAPaths[payment.PaymentMethod]; ok {
+		return dc.CreateVirtualAccount(ctx, payment)
+	}
+	switch payment.PaymentMethod {
+	case "QR":
+		return dc.CreateQRCode(ctx, payment)
+	case "DEFAULT":
+		return dc.CreateCheckout(ctx, payment)
+	default:
+		return "", fmt.Errorf("%w: %q for Doku", domain.ErrUnsupportedPaymentMethod, payment.PaymentMethod)
 	}
 }
 
-func (xc *DokuClient) ProcessPayment(ctx context.Context, payment *domain.Payment) (string, error) {
+// CreateCheckout creates a DOKU Checkout page offering every channel enabled on the merchant account.
+func (dc *DokuClient) CreateCheckout(ctx context.Context, payment *domain.Payment) (string, error) {
+	return dc.createCheckout(ctx, payment, nil)
+}
 
-	return payment.PaymentID, nil
+func (dc *DokuClient) RefundPayment(ctx context.Context, payment *domain.Payment, amount domain.Money) (*domain.GatewayRefund, error) {
+	return nil, fmt.Errorf("%w: DOKU refunds are not integrated", domain.ErrRefundNotSupported)
 }
 
-func (xc *DokuClient) RefundPayment(ctx context.Context, paymentID string, amount float64) (string, error) {
-	// Implement refund logic with Doku if available, as Doku primarily supports invoice-based payments
-	return "", nil
+// ChargeEWallet creates a DOKU Checkout page restricted to the requested e-wallet.
+func (dc *DokuClient) ChargeEWallet(ctx context.Context, payment *domain.Payment) (string, error) {
+	channel, ok := dokuEWalletChannels[payment.PaymentMethod]
+	if !ok {
+		return "", fmt.Errorf("unsupported e-wallet %q for Doku", payment.PaymentMethod)
+	}
+	return dc.createCheckout(ctx, payment, []string{channel})
 }
 
-func (xc *DokuClient) ChargeEWallet(ctx context.Context, payment *domain.Payment) (string, error) {
+func (dc *DokuClient) CreateVirtualAccount(ctx context.Context, payment *domain.Payment) (string, error) {
+	path, ok := dokuVAPaths[payment.PaymentMethod]
+	if !ok {
+		return "", fmt.Errorf("unsupported virtual account bank %q for Doku", payment.PaymentMethod)
+	}
+
+	amount, err := dokuAmount(payment.Amount)
+	if err != nil {
+		return "", err
+	}
+
+	req := dokuVARequest{
+		Order: dokuOrder{
+			InvoiceNumber: payment.ExternalID,
+			Amount:        amount,
+		},
+		Customer: dokuCustomer{Name: payment.UserID},
+	}
+	req.VirtualAccountInfo.BillingType = "FIX_BILL"
+	req.VirtualAccountInfo.ExpiredTime = dokuVAExpiryMinutes
+	req.VirtualAccountInfo.ReusableStatus = false
+
+	var resp dokuVAResponse
+	log.Printf("Sending request to Doku to create virtual account: %+v\n", req)
+	if err := dc.do(ctx, http.MethodPost, path, req, &resp); err != nil {
+		log.Printf("Error creating virtual account with Doku: %v\n", err)
+		return "", err
+	}
+
+	payment.ExpiresAt = time.Now().Add(dokuVAExpiryMinutes * time.Minute)
 
-	return payment.PaymentID, nil
+	log.Printf("Virtual account created successfully with number: %s\n", resp.VirtualAccountInfo.VirtualAccountNumber)
+	return resp.VirtualAccountInfo.VirtualAccountNumber, nil
 }
 
-func (xc *DokuClient) CreateVirtualAccount(ctx context.Context, payment *domain.Payment) (string, error) {
+// CreateQRCode creates a DOKU Checkout page restricted to QRIS; DOKU renders the QR code itself.
+func (dc *DokuClient) CreateQRCode(ctx context.Context, payment *domain.Payment) (string, error) {
+	return dc.createCheckout(ctx, payment, []string{"QRIS"})
+}
+
+func (dc *DokuClient) createCheckout(ctx context.Context, payment *domain.Payment, channels []string) (string, error) {
+	amount, err := dokuAmount(payment.Amount)
+	if err != nil {
+		return "", err
+	}
+
+	req := dokuCheckoutRequest{
+		Order: dokuOrder{
+			InvoiceNumber: payment.ExternalID,
+			Amount:        amount,
+			Currency:      payment.Amount.Currency,
+			LineItems:     make([]dokuLineItem, len(payment.Items)),
+		},
+		Customer: dokuCustomer{ID: payment.UserID, Phone: payment.PhoneNumber},
+	}
+	for i, item := range payment.Items {
+		price, err := dokuAmount(item.Price)
+		if err != nil {
+			return "", err
+		}
+		req.Order.LineItems[i] = dokuLineItem{Name: item.ItemName, Price: price, Quantity: item.Quantity}
+	}
+	req.Payment.PaymentDueDate = dokuCheckoutDueMinutes
+	req.Payment.PaymentMethodTypes = channels
 
-	return payment.PaymentID, nil
+	var resp dokuCheckoutResponse
+	log.Printf("Sending request to Doku to create checkout: %+v\n", req)
+	if err := dc.do(ctx, http.MethodPost, dokuCheckoutPath, req, &resp); err != nil {
+		log.Printf("Error creating checkout with Doku: %v\n", err)
+		return "", err
+	}
+
+	payment.ExpiresAt = time.Now().Add(dokuCheckoutDueMinutes * time.Minute)
+
+	log.Printf("Checkout created successfully with token: %s\n", resp.Response.Payment.TokenID)
+	return resp.Response.Payment.TokenID, nil
 }
 
-func (xc *DokuClient) CreateQRCode(ctx context.Context, payment *domain.Payment) (string, error) {
+// do sends a signed request to DOKU and decodes a 2xx response into result.
+func (dc *DokuClient) do(ctx context.Context, method, path string, body, result interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
 
-	return payment.PaymentID, nil
+	req, err := http.NewRequestWithContext(ctx, method, dc.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	requestID := uuid.New().String()
+	timestamp := time.Now().UTC().Format(dokuTimestampLayout)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Client-Id", dc.clientID)
+	req.Header.Set("Request-Id", requestID)
+	req.Header.Set("Request-Timestamp", timestamp)
+	req.Header.Set("Signature", dokuSignature(dc.secretKey, dc.clientID, requestID, timestamp, path, payload))
+
+	resp, err := dc.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		dokuErr := &DokuError{StatusCode: resp.StatusCode, Message: string(respBody)}
+		var errResp dokuErrorResponse
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Error.Code != "" {
+			dokuErr.Code = errResp.Error.Code
+			dokuErr.Type = errResp.Error.Type
+			dokuErr.Message = errResp.Error.Message
+		}
+		return dokuErr
+	}
+
+	return json.Unmarshal(respBody, result)
+}
+
+// VerifyNotification checks the Signature header of a notification DOKU sent
+// to target, our notification path, against our client ID and secret key.
+// Notifications signed more than dokuNotificationTolerance from now are
+// rejected before the signature is checked.
+func (dc *DokuClient) VerifyNotification(clientID, requestID, timestamp, target string, body []byte, signature string) error {
+	if dc.secretKey == "" || clientID != dc.clientID {
+		return ErrDokuInvalidSignature
+	}
+	signedAt, err := time.Parse(dokuTimestampLayout, timestamp)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDokuStaleNotification, err)
+	}
+	if age := time.Since(signedAt); age > dokuNotificationTolerance || age < -dokuNotificationTolerance {
+		return ErrDokuStaleNotification
+	}
+	expected := dokuSignature(dc.secretKey, clientID, requestID, timestamp, target, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrDokuInvalidSignature
+	}
+	return nil
+}
+
+// dokuSignature computes DOKU's Signature header: an HMAC-SHA256 over the
+// Client-Id, Request-Id, Request-Timestamp, Request-Target and body Digest.
+func dokuSignature(secretKey, clientID, requestID, timestamp, target string, body []byte) string {
+	components := []string{
+		"Client-Id:" + clientID,
+		"Request-Id:" + requestID,
+		"Request-Timestamp:" + timestamp,
+		"Request-Target:" + target,
+	}
+	if len(body) > 0 {
+		digest := sha256.Sum256(body)
+		components = append(components, "Digest:"+base64.StdEncoding.EncodeToString(digest[:]))
+	}
+
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(strings.Join(components, "\n")))
+	return "HMACSHA256=" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// dokuAmount converts an amount to the whole-rupiah integers DOKU accepts.
+func dokuAmount(amount domain.Money) (int64, error) {
+	if amount.Currency != "IDR" {
+		return 0, fmt.Errorf("%w: DOKU only accepts IDR, got %s", domain.ErrUnsupportedCurrency, amount.Currency)
+	}
+	return amount.MinorUnitsWithExponent(0)
 }
@@ -0,0 +1,324 @@
+package paymentgateway
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"payment-service/internal/domain"
+	"testing"
+	"time"
+)
+
+const (
+	testDokuClientID  = "MCH-0001-0000000000001"
+	testDokuSecretKey = "SK-test-secret"
+)
+
+// dokuRequest is a request the DOKU stand-in received.
+type dokuRequest struct {
+	method  string
+	path    string
+	headers http.Header
+	body    []byte
+}
+
+// newDokuServer starts a DOKU stand-in answering every request with status and
+// body, and returns a client for it and the requests it received.
+func newDokuServer(t *testing.T, status int, body string) (*DokuClient, *[]dokuRequest) {
+	t.Helper()
+	var requests []dokuRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading request body: %v", err)
+		}
+		requests = append(requests, dokuRequest{method: r.Method, path: r.URL.Path, headers: r.Header.Clone(), body: payload})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	client := NewDokuClientWithConfig(testDokuClientID, testDokuSecretKey, server.URL+"/", server.Client())
+	return client, &requests
+}
+
+func newDokuPayment(t *testing.T, method string) *domain.Payment {
+	t.Helper()
+	amount, err := domain.MoneyFromMajor(150000, "IDR")
+	if err != nil {
+		t.Fatalf("MoneyFromMajor() error = %v", err)
+	}
+	return &domain.Payment{
+		PaymentID:     "pay-1",
+		ExternalID:    "INV-20240501-0001",
+		UserID:        "user-1",
+		PhoneNumber:   "+6281234567890",
+		Amount:        amount,
+		PaymentMethod: method,
+		Items: []domain.Item{
+			{ItemName: "Coffee", Quantity: 2, Price: domain.Money{MinorUnits: amount.MinorUnits / 2, Currency: "IDR"}},
+		},
+	}
+}
+
+// expectedDokuSignature computes the Signature header as DOKU documents it,
+// independently of dokuSignature.
+func expectedDokuSignature(requestID, timestamp, target string, body []byte) string {
+	digest := sha256.Sum256(body)
+	component := "Client-Id:" + testDokuClientID + "\n" +
+		"Request-Id:" + requestID + "\n" +
+		"Request-Timestamp:" + timestamp + "\n" +
+		"Request-Target:" + target + "\n" +
+		"Digest:" + base64.StdEncoding.EncodeToString(digest[:])
+	mac := hmac.New(sha256.New, []byte(testDokuSecretKey))
+	mac.Write([]byte(component))
+	return "HMACSHA256=" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestDokuProcessPaymentSignsRequests(t *testing.T) {
+	tests := []struct {
+		method    string
+		path      string
+		response  string
+		reference string
+		channels  []string
+		expiry    time.Duration
+	}{
+		{
+			method:    "DEFAULT",
+			path:      dokuCheckoutPath,
+			response:  `{"response":{"payment":{"token_id":"tok-1","url":"https://checkout.doku.com/tok-1"}}}`,
+			reference: "tok-1",
+			expiry:    dokuCheckoutDueMinutes * time.Minute,
+		},
+		{
+			method:    "QR",
+			path:      dokuCheckoutPath,
+			response:  `{"response":{"payment":{"token_id":"tok-2"}}}`,
+			reference: "tok-2",
+			channels:  []string{"QRIS"},
+			expiry:    dokuCheckoutDueMinutes * time.Minute,
+		},
+		{
+			method:    "OVO",
+			path:      dokuCheckoutPath,
+			response:  `{"response":{"payment":{"token_id":"tok-3"}}}`,
+			reference: "tok-3",
+			channels:  []string{"EMONEY_OVO"},
+			expiry:    dokuCheckoutDueMinutes * time.Minute,
+		},
+		{
+			method:    "BCA",
+			path:      "/bca-virtual-account/v2/payment-code",
+			response:  `{"virtual_account_info":{"virtual_account_number":"1900800000000001"}}`,
+			reference: "1900800000000001",
+			expiry:    dokuVAExpiryMinutes * time.Minute,
+		},
+		{
+			method:    "MANDIRI",
+			path:      "/mandiri-virtual-account/v2/payment-code",
+			response:  `{"virtual_account_info":{"virtual_account_number":"8900800000000001"}}`,
+			reference: "8900800000000001",
+			expiry:    dokuVAExpiryMinutes * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			client, requests := newDokuServer(t, http.StatusOK, tt.response)
+			payment := newDokuPayment(t, tt.method)
+
+			reference, err := client.ProcessPayment(context.Background(), payment)
+			if err != nil {
+				t.Fatalf("ProcessPayment() error = %v", err)
+			}
+			if reference != tt.reference {
+				t.Errorf("ProcessPayment() = %q, want %q", reference, tt.reference)
+			}
+			if until := time.Until(payment.ExpiresAt); until <= tt.expiry-time.Minute || until > tt.expiry {
+				t.Errorf("ExpiresAt in %s, want %s", until, tt.expiry)
+			}
+
+			if len(*requests) != 1 {
+				t.Fatalf("DOKU received %d requests, want 1", len(*requests))
+			}
+			req := (*requests)[0]
+			if req.method != http.MethodPost || req.path != tt.path {
+				t.Errorf("request = %s %s, want POST %s", req.method, req.path, tt.path)
+			}
+			if got := req.headers.Get("Client-Id"); got != testDokuClientID {
+				t.Errorf("Client-Id = %q, want %q", got, testDokuClientID)
+			}
+			requestID := req.headers.Get("Request-Id")
+			if requestID == "" {
+				t.Error("Request-Id header missing")
+			}
+			timestamp := req.headers.Get("Request-Timestamp")
+			if _, err := time.Parse(dokuTimestampLayout, timestamp); err != nil {
+				t.Errorf("Request-Timestamp = %q: %v", timestamp, err)
+			}
+			if got, want := req.headers.Get("Signature"), expectedDokuSignature(requestID, timestamp, tt.path, req.body); got != want {
+				t.Errorf("Signature = %q, want %q", got, want)
+			}
+
+			var body struct {
+				Order struct {
+					InvoiceNumber string `json:"invoice_number"`
+					Amount        int64  `json:"amount"`
+				} `json:"order"`
+				Payment struct {
+					PaymentMethodTypes []string `json:"payment_method_types"`
+				} `json:"payment"`
+			}
+			if err := json.Unmarshal(req.body, &body); err != nil {
+				t.Fatalf("request body %s: %v", req.body, err)
+			}
+			if body.Order.InvoiceNumber != payment.ExternalID || body.Order.Amount != 150000 {
+				t.Errorf("order = %+v, want invoice %s for 150000", body.Order, payment.ExternalID)
+			}
+			if len(body.Payment.PaymentMethodTypes) != len(tt.channels) ||
+				(len(tt.channels) > 0 && body.Payment.PaymentMethodTypes[0] != tt.channels[0]) {
+				t.Errorf("payment_method_types = %v, want %v", body.Payment.PaymentMethodTypes, tt.channels)
+			}
+		})
+	}
+}
+
+func TestDokuProcessPaymentClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		want      error
+		code      string
+		retryable bool
+	}{
+		{name: "bad request", status: http.StatusBadRequest, body: `{"error":{"code":"invalid_parameter","message":"amount is required","type":"invalid_request_error"}}`, want: ErrDokuInvalidRequest, code: "invalid_parameter"},
+		{name: "unauthorized", status: http.StatusUnauthorized, body: `{"error":{"code":"invalid_signature","message":"Invalid signature"}}`, want: ErrDokuUnauthorized, code: "invalid_signature"},
+		{name: "forbidden", status: http.StatusForbidden, body: `forbidden`, want: ErrDokuUnauthorized},
+		{name: "not found", status: http.StatusNotFound, body: `{}`, want: ErrDokuNotFound},
+		{name: "conflict", status: http.StatusConflict, body: `{"error":{"code":"duplicate_invoice","message":"Invoice number already used"}}`, want: ErrDokuInvalidRequest, code: "duplicate_invoice"},
+		{name: "rate limited", status: http.StatusTooManyRequests, body: `{}`, want: ErrDokuUnavailable, retryable: true},
+		{name: "server error", status: http.StatusInternalServerError, body: `internal error`, want: ErrDokuUnavailable, retryable: true},
+		{name: "unavailable", status: http.StatusServiceUnavailable, body: `{}`, want: ErrDokuUnavailable, retryable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newDokuServer(t, tt.status, tt.body)
+
+			_, err := client.ProcessPayment(context.Background(), newDokuPayment(t, "DEFAULT"))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("ProcessPayment() error = %v, want %v", err, tt.want)
+			}
+			var dokuErr *DokuError
+			if !errors.As(err, &dokuErr) {
+				t.Fatalf("ProcessPayment() error = %v, want a *DokuError", err)
+			}
+			if dokuErr.StatusCode != tt.status || dokuErr.Code != tt.code {
+				t.Errorf("DokuError = %+v, want status %d and code %q", dokuErr, tt.status, tt.code)
+			}
+			if got := domain.IsRetryableGatewayError(err); got != tt.retryable {
+				t.Errorf("IsRetryableGatewayError() = %t, want %t", got, tt.retryable)
+			}
+			// Rate limiting and server errors are DOKU's, not the payment method's.
+			if got := domain.IsGatewayWideError(err); got != tt.retryable {
+				t.Errorf("IsGatewayWideError() = %t, want %t", got, tt.retryable)
+			}
+			if domain.IsAmbiguousGatewayError(err) {
+				t.Error("IsAmbiguousGatewayError() = true for a DOKU response")
+			}
+		})
+	}
+}
+
+func TestDokuProcessPaymentClassifiesTransportErrors(t *testing.T) {
+	t.Run("connection refused", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		client := NewDokuClientWithConfig(testDokuClientID, testDokuSecretKey, server.URL, &http.Client{})
+
+		_, err := client.ProcessPayment(context.Background(), newDokuPayment(t, "DEFAULT"))
+		if !domain.IsRetryableGatewayError(err) || domain.IsAmbiguousGatewayError(err) || !domain.IsGatewayWideError(err) {
+			t.Errorf("ProcessPayment() error = %v, want retryable, gateway-wide and not ambiguous", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer server.Close()
+		defer close(release)
+		client := NewDokuClientWithConfig(testDokuClientID, testDokuSecretKey, server.URL, &http.Client{Timeout: 50 * time.Millisecond})
+
+		_, err := client.ProcessPayment(context.Background(), newDokuPayment(t, "DEFAULT"))
+		if domain.IsRetryableGatewayError(err) || !domain.IsAmbiguousGatewayError(err) || !domain.IsGatewayWideError(err) {
+			t.Errorf("ProcessPayment() error = %v, want ambiguous, gateway-wide and not retryable", err)
+		}
+	})
+}
+
+func TestDokuVerifyNotification(t *testing.T) {
+	client := NewDokuClientWithConfig(testDokuClientID, testDokuSecretKey, dokuDefaultBaseURL, http.DefaultClient)
+	body := []byte(`{"order":{"invoice_number":"INV-20240501-0001"},"transaction":{"status":"SUCCESS"}}`)
+	const (
+		requestID = "3f1b2c9e-0d7a-4d55-9c1e-6a3e8f0b1a22"
+		target    = "/webhooks/doku"
+	)
+	timestamp := time.Now().UTC().Format(dokuTimestampLayout)
+	signature := expectedDokuSignature(requestID, timestamp, target, body)
+
+	if err := client.VerifyNotification(testDokuClientID, requestID, timestamp, target, body, signature); err != nil {
+		t.Errorf("VerifyNotification() error = %v", err)
+	}
+	if err := client.VerifyNotification(testDokuClientID, requestID, timestamp, target, []byte(`{}`), signature); !errors.Is(err, ErrDokuInvalidSignature) {
+		t.Errorf("VerifyNotification() of a changed body error = %v, want %v", err, ErrDokuInvalidSignature)
+	}
+	if err := client.VerifyNotification("MCH-other", requestID, timestamp, target, body, signature); !errors.Is(err, ErrDokuInvalidSignature) {
+		t.Errorf("VerifyNotification() for another client error = %v, want %v", err, ErrDokuInvalidSignature)
+	}
+}
+
+func TestDokuVerifyNotificationRejectsStaleTimestamps(t *testing.T) {
+	client := NewDokuClientWithConfig(testDokuClientID, testDokuSecretKey, dokuDefaultBaseURL, http.DefaultClient)
+	body := []byte(`{"order":{"invoice_number":"INV-20240501-0001"},"transaction":{"status":"SUCCESS"}}`)
+	const (
+		requestID = "3f1b2c9e-0d7a-4d55-9c1e-6a3e8f0b1a22"
+		target    = "/webhooks/doku"
+	)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		want      error
+	}{
+		{name: "within tolerance", timestamp: time.Now().Add(-4 * time.Minute).UTC().Format(dokuTimestampLayout)},
+		{name: "slightly ahead", timestamp: time.Now().Add(4 * time.Minute).UTC().Format(dokuTimestampLayout)},
+		{name: "replayed", timestamp: time.Now().Add(-6 * time.Minute).UTC().Format(dokuTimestampLayout), want: ErrDokuStaleNotification},
+		{name: "far ahead", timestamp: time.Now().Add(6 * time.Minute).UTC().Format(dokuTimestampLayout), want: ErrDokuStaleNotification},
+		{name: "malformed", timestamp: "yesterday", want: ErrDokuStaleNotification},
+		{name: "missing", timestamp: "", want: ErrDokuStaleNotification},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Validly signed, so only the timestamp can be refused.
+			signature := expectedDokuSignature(requestID, tt.timestamp, target, body)
+			err := client.VerifyNotification(testDokuClientID, requestID, tt.timestamp, target, body, signature)
+			if tt.want == nil && err != nil {
+				t.Errorf("VerifyNotification() error = %v", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("VerifyNotification() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
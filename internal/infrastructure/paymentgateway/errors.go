@@ -0,0 +1,103 @@
+package paymentgateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"payment-service/internal/domain"
+	"strings"
+
+	"github.com/stripe/stripe-go/v72"
+	"github.com/xendit/xendit-go"
+)
+
+// isTransportError reports whether err is a failure to reach a gateway or to
+// read its response, after which the request may or may not have been served.
+func isTransportError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// isConnectError reports whether err is a failure to connect to a gateway, so
+// that no request reached it.
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return (errors.As(err, &opErr) && opErr.Op == "dial") || errors.As(err, &dnsErr)
+}
+
+// classifyTransportError marks a transport error of a gateway call retryable
+// if the request never reached the gateway, and ambiguous otherwise. Either is
+// a failure of the whole gateway.
+func classifyTransportError(gatewayErr *domain.GatewayError, err error) {
+	switch {
+	case isConnectError(err):
+		gatewayErr.Retryable = true
+		gatewayErr.GatewayWide = true
+	case isTransportError(err):
+		gatewayErr.Ambiguous = true
+		gatewayErr.GatewayWide = true
+	}
+}
+
+// isRetryableStatus reports whether an HTTP status of a gateway response asks
+// for the request to be tried again.
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// xenditGatewayError classifies an error of a Xendit call. The SDK reports
+// transport failures, and responses it could not read, as GO_ERROR errors
+// keeping only their message.
+func xenditGatewayError(err error) error {
+	gatewayErr := &domain.GatewayError{Gateway: domain.GatewayXendit, Err: err}
+	var xerr *xendit.Error
+	switch {
+	case errors.As(err, &xerr) && xerr.ErrorCode == xendit.GoErrCode:
+		gatewayErr.Retryable = strings.Contains(xerr.Message, "dial tcp")
+		gatewayErr.Ambiguous = !gatewayErr.Retryable
+		gatewayErr.GatewayWide = true
+	case errors.As(err, &xerr):
+		gatewayErr.Retryable = isRetryableStatus(xerr.Status)
+		gatewayErr.GatewayWide = gatewayErr.Retryable
+	default:
+		classifyTransportError(gatewayErr, err)
+	}
+	return gatewayErr
+}
+
+// stripeGatewayError classifies an error of a Stripe call. Card errors are
+// declines and never retryable.
+func stripeGatewayError(err error) error {
+	gatewayErr := &domain.GatewayError{Gateway: domain.GatewayStripe, Err: err}
+	var serr *stripe.Error
+	switch {
+	case errors.As(err, &serr) && serr.Type == stripe.ErrorTypeAPIConnection:
+		gatewayErr.Ambiguous = true
+		gatewayErr.GatewayWide = true
+	case errors.As(err, &serr):
+		gatewayErr.Retryable = serr.Type == stripe.ErrorTypeRateLimit || isRetryableStatus(serr.HTTPStatusCode)
+		gatewayErr.GatewayWide = gatewayErr.Retryable
+	default:
+		classifyTransportError(gatewayErr, err)
+	}
+	return gatewayErr
+}
+
+// dokuGatewayError classifies an error of a DOKU call.
+func dokuGatewayError(err error) error {
+	gatewayErr := &domain.GatewayError{Gateway: domain.GatewayDoku, Err: err}
+	var dokuErr *DokuError
+	if errors.As(err, &dokuErr) {
+		gatewayErr.Retryable = errors.Is(err, ErrDokuUnavailable)
+		gatewayErr.GatewayWide = gatewayErr.Retryable
+	} else {
+		classifyTransportError(gatewayErr, err)
+	}
+	return gatewayErr
+}
@@ -2,26 +2,105 @@ package paymentgateway
 
 import (
 	"context"
+	"log"
 	"payment-service/api/proto"
+	"payment-service/internal/domain"
+	"sync"
 	"time"
 
 	"google.golang.org/grpc"
 )
 
+// PaymentConfigClient looks up the gateways to use for a payment method. Routes
+// are cached for ttl; an older route is still returned while it is refreshed in
+// the background, so a slow or unavailable config service does not hold up
+// payments. Every route fetched is also stored in routes and loaded from there
+// on startup, so the last known routing survives restarts.
 type PaymentConfigClient struct {
 	client  proto.PaymentConfigServiceClient
 	timeout time.Duration
+	ttl     time.Duration
+	routes  domain.GatewayRouteRepository
+
+	mu    sync.Mutex
+	cache map[string]domain.GatewayRoute
+	// refreshing holds the payment methods whose route is being refreshed.
+	refreshing map[string]bool
 }
 
-func NewPaymentConfigClient(conn *grpc.ClientConn, timeout time.Duration) *PaymentConfigClient {
-	return &PaymentConfigClient{
-		client:  proto.NewPaymentConfigServiceClient(conn),
-		timeout: timeout,
+func NewPaymentConfigClient(conn *grpc.ClientConn, timeout, ttl time.Duration, routes domain.GatewayRouteRepository) *PaymentConfigClient {
+	pcc := &PaymentConfigClient{
+		client:     proto.NewPaymentConfigServiceClient(conn),
+		timeout:    timeout,
+		ttl:        ttl,
+		routes:     routes,
+		cache:      make(map[string]domain.GatewayRoute),
+		refreshing: make(map[string]bool),
 	}
+	pcc.loadSnapshot()
+	return pcc
 }
 
-func (pcc *PaymentConfigClient) GetPaymentGatewayConfig(paymentMethod string) (string, string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), pcc.timeout)
+// loadSnapshot fills the cache with the stored routes. They are expired, so
+// each is refreshed the first time it is used.
+func (pcc *PaymentConfigClient) loadSnapshot() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	routes, err := pcc.routes.FindAll(ctx)
+	if err != nil {
+		log.Printf("Error loading stored gateway routes: %v", err)
+		return
+	}
+	for _, route := range routes {
+		route.FetchedAt = time.Time{}
+		pcc.cache[route.PaymentMethod] = route
+	}
+	log.Printf("Loaded %d stored gateway routes", len(routes))
+}
+
+// GetPaymentGatewayConfig returns the gateways to try for paymentMethod, in
+// order. Only a payment method without a known route waits for the config
+// service.
+func (pcc *PaymentConfigClient) GetPaymentGatewayConfig(ctx context.Context, paymentMethod string) ([]string, error) {
+	pcc.mu.Lock()
+	route, ok := pcc.cache[paymentMethod]
+	stale := ok && time.Since(route.FetchedAt) >= pcc.ttl
+	if stale && !pcc.refreshing[paymentMethod] {
+		pcc.refreshing[paymentMethod] = true
+		// The refresh outlives the request that noticed the route was stale.
+		go pcc.refresh(paymentMethod)
+	}
+	pcc.mu.Unlock()
+
+	if ok {
+		return route.Gateways, nil
+	}
+	route, err := pcc.fetch(ctx, paymentMethod)
+	if err != nil {
+		return nil, err
+	}
+	return route.Gateways, nil
+}
+
+// refresh fetches the route of paymentMethod in the background, keeping the
+// cached one if the config service cannot be reached.
+func (pcc *PaymentConfigClient) refresh(paymentMethod string) {
+	defer func() {
+		pcc.mu.Lock()
+		delete(pcc.refreshing, paymentMethod)
+		pcc.mu.Unlock()
+	}()
+
+	if _, err := pcc.fetch(context.Background(), paymentMethod); err != nil {
+		log.Printf("Error refreshing gateway route of %s, keeping the cached one: %v", paymentMethod, err)
+	}
+}
+
+// fetch asks the config service for the route of paymentMethod and caches and
+// stores it.
+func (pcc *PaymentConfigClient) fetch(ctx context.Context, paymentMethod string) (domain.GatewayRoute, error) {
+	ctx, cancel := context.WithTimeout(ctx, pcc.timeout)
 	defer cancel()
 
 	req := &proto.PaymentMethodRequest{
@@ -30,8 +109,35 @@ func (pcc *PaymentConfigClient) GetPaymentGatewayConfig(paymentMethod string) (s
 
 	resp, err := pcc.client.GetPaymentGatewayConfig(ctx, req)
 	if err != nil {
-		return "", "", err
+		return domain.GatewayRoute{}, err
 	}
 
-	return resp.Gateway, resp.FallbackGateway, nil
+	route := domain.GatewayRoute{
+		PaymentMethod: paymentMethod,
+		Gateways:      responseGateways(resp),
+		FetchedAt:     time.Now(),
+	}
+	pcc.mu.Lock()
+	pcc.cache[paymentMethod] = route
+	pcc.mu.Unlock()
+
+	if err := pcc.routes.Upsert(ctx, route); err != nil {
+		log.Printf("Error storing gateway route of %s: %v", paymentMethod, err)
+	}
+	return route, nil
+}
+
+// responseGateways returns the ordered gateways of a config service response.
+func responseGateways(resp *proto.PaymentGatewayResponse) []string {
+	if len(resp.Gateways) > 0 {
+		return resp.Gateways
+	}
+	// The config service predating gateway lists sends a gateway and a fallback.
+	var gateways []string
+	for _, gateway := range []string{resp.Gateway, resp.FallbackGateway} {
+		if gateway != "" {
+			gateways = append(gateways, gateway)
+		}
+	}
+	return gateways
 }
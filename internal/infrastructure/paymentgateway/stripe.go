@@ -3,8 +3,12 @@ package paymentgateway
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"os"
 	"payment-service/internal/domain"
+	"strings"
+	"time"
 
 	"github.com/stripe/stripe-go/v72"
 	"github.com/stripe/stripe-go/v72/paymentintent"
@@ -21,17 +25,52 @@ func NewStripeClient() *StripeClient {
 	}
 }
 
+// stripePaymentMethodTypes maps our payment methods to Stripe payment method types.
+var stripePaymentMethodTypes = map[string]string{
+	"STP-DEFAULT": "card",
+}
+
+func (sc *StripeClient) SupportedMethods() []string {
+	methods := make([]string, 0, len(stripePaymentMethodTypes))
+	for method := range stripePaymentMethodTypes {
+		methods = append(methods, method)
+	}
+	return methods
+}
+
+// ProcessPayment creates a PaymentIntent for the payment and classifies the
+// error of a failed call as a *domain.GatewayError.
 func (sc *StripeClient) ProcessPayment(ctx context.Context, payment *domain.Payment) (string, error) {
+	reference, err := sc.createPaymentIntent(ctx, payment)
+	if err != nil {
+		return "", stripeGatewayError(err)
+	}
+	return reference, nil
+}
+
+func (sc *StripeClient) createPaymentIntent(ctx context.Context, payment *domain.Payment) (string, error) {
 	stripe.Key = sc.apiKey
 
+	methodType, ok := stripePaymentMethodTypes[payment.PaymentMethod]
+	if !ok {
+		return "", fmt.Errorf("%w: %q for Stripe", domain.ErrUnsupportedPaymentMethod, payment.PaymentMethod)
+	}
+
+	amount, err := stripeAmount(payment.Amount)
+	if err != nil {
+		return "", err
+	}
+
 	params := &stripe.PaymentIntentParams{
-		Amount:   stripe.Int64(int64(payment.Amount * 100)), // Stripe accepts amounts in cents
-		Currency: stripe.String(payment.Currency),
+		Amount:   stripe.Int64(amount),
+		Currency: stripe.String(strings.ToLower(payment.Amount.Currency)),
 		PaymentMethodTypes: stripe.StringSlice([]string{
-			payment.PaymentMethod,
+			methodType,
 		}),
 	}
 
+	params.AddMetadata("external_id", payment.ExternalID)
+
 	pi, err := paymentintent.New(params)
 	if err != nil {
 		return "", err
@@ -40,18 +79,159 @@ func (sc *StripeClient) ProcessPayment(ctx context.Context, payment *domain.Paym
 	return pi.ID, nil
 }
 
-func (sc *StripeClient) RefundPayment(ctx context.Context, paymentID string, amount float64) (string, error) {
+func (sc *StripeClient) RefundPayment(ctx context.Context, payment *domain.Payment, amount domain.Money) (*domain.GatewayRefund, error) {
 	stripe.Key = sc.apiKey
 
+	stripeRefundAmount, err := stripeAmount(amount)
+	if err != nil {
+		return nil, err
+	}
+
 	params := &stripe.RefundParams{
-		PaymentIntent: stripe.String(paymentID),
-		Amount:        stripe.Int64(int64(amount * 100)),
+		PaymentIntent: stripe.String(payment.GatewayReference),
+		Amount:        stripe.Int64(stripeRefundAmount),
 	}
 
 	refund, err := refund.New(params)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	return &domain.GatewayRefund{GatewayRefundID: refund.ID, Status: stripeRefundStatus(refund.Status)}, nil
+}
+
+// stripeRefundStatus maps the status of a Stripe refund to our refund status.
+// Card refunds are usually pending until the card network confirms them.
+func stripeRefundStatus(status stripe.RefundStatus) domain.RefundStatus {
+	switch status {
+	case stripe.RefundStatusSucceeded:
+		return domain.RefundStatusSucceeded
+	case stripe.RefundStatusFailed, stripe.RefundStatusCanceled:
+		return domain.RefundStatusFailed
+	default:
+		return domain.RefundStatusPending
 	}
+}
 
-	return refund.ID, nil
+// CancelPayment cancels the PaymentIntent of a pending payment.
+func (sc *StripeClient) CancelPayment(ctx context.Context, payment *domain.Payment) error {
+	stripe.Key = sc.apiKey
+
+	params := &stripe.PaymentIntentCancelParams{
+		CancellationReason: stripe.String(string(stripe.PaymentIntentCancellationReasonAbandoned)),
+	}
+	_, err := paymentintent.Cancel(payment.GatewayReference, params)
+	return err
+}
+
+// ListTransactions lists the PaymentIntents created between from and to. Their
+// external ID is the one we stored in their metadata.
+func (sc *StripeClient) ListTransactions(ctx context.Context, from, to time.Time) ([]domain.GatewayTransaction, error) {
+	stripe.Key = sc.apiKey
+
+	params := &stripe.PaymentIntentListParams{
+		CreatedRange: &stripe.RangeQueryParams{
+			GreaterThanOrEqual: from.Unix(),
+			LesserThan:         to.Unix(),
+		},
+	}
+	params.Context = ctx
+
+	var transactions []domain.GatewayTransaction
+	it := paymentintent.List(params)
+	for it.Next() {
+		pi := it.PaymentIntent()
+		amount, err := stripeMoney(pi.Amount, string(pi.Currency))
+		if err != nil {
+			return nil, fmt.Errorf("stripe payment intent %s: %w", pi.ID, err)
+		}
+		transactions = append(transactions, domain.GatewayTransaction{
+			Gateway:          domain.GatewayStripe,
+			ExternalID:       pi.Metadata["external_id"],
+			GatewayReference: pi.ID,
+			Amount:           amount,
+			Status:           stripePaymentIntentStatus(pi),
+			CreatedAt:        time.Unix(pi.Created, 0),
+		})
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// FindTransaction looks up the PaymentIntent created for payment through the
+// external ID stored in its metadata.
+func (sc *StripeClient) FindTransaction(ctx context.Context, payment *domain.Payment) (*domain.GatewayTransaction, error) {
+	stripe.Key = sc.apiKey
+
+	params := &stripe.PaymentIntentSearchParams{}
+	params.Query = fmt.Sprintf("metadata['external_id']:'%s'", payment.ExternalID)
+	params.Context = ctx
+
+	it := paymentintent.Search(params)
+	if !it.Next() {
+		if err := it.Err(); err != nil {
+			return nil, err
+		}
+		return nil, domain.ErrGatewayTransactionNotFound
+	}
+	pi := it.PaymentIntent()
+	amount, err := stripeMoney(pi.Amount, string(pi.Currency))
+	if err != nil {
+		return nil, fmt.Errorf("stripe payment intent %s: %w", pi.ID, err)
+	}
+	return &domain.GatewayTransaction{
+		Gateway:          domain.GatewayStripe,
+		ExternalID:       payment.ExternalID,
+		GatewayReference: pi.ID,
+		Amount:           amount,
+		Status:           stripePaymentIntentStatus(pi),
+		CreatedAt:        time.Unix(pi.Created, 0),
+	}, nil
+}
+
+// stripePaymentIntentStatus returns the payment status of a PaymentIntent. One
+// waiting for a new payment method after a failed attempt can still be paid,
+// so it is pending; only a canceled one failed, unless we cancelled it.
+func stripePaymentIntentStatus(pi *stripe.PaymentIntent) domain.PaymentStatus {
+	switch pi.Status {
+	case stripe.PaymentIntentStatusSucceeded:
+		return domain.PaymentStatusPaid
+	case stripe.PaymentIntentStatusCanceled:
+		// CancelPayment cancels PaymentIntents as abandoned.
+		if pi.CancellationReason == stripe.PaymentIntentCancellationReasonAbandoned {
+			return domain.PaymentStatusCancelled
+		}
+		return domain.PaymentStatusFailed
+	default:
+		return domain.PaymentStatusPending
+	}
+}
+
+// stripeCurrencyExponents lists the currencies Stripe does not express in
+// hundredths. Stripe's list differs from ISO 4217 and from ours: it takes IDR,
+// for instance, with two decimals.
+var stripeCurrencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "JPY": 0, "KMF": 0, "KRW": 0, "MGA": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "JOD": 3, "KWD": 3, "OMR": 3, "TND": 3,
+}
+
+// stripeAmount converts an amount to the integer Stripe expects for its currency.
+func stripeAmount(amount domain.Money) (int64, error) {
+	exponent, ok := stripeCurrencyExponents[strings.ToUpper(amount.Currency)]
+	if !ok {
+		exponent = 2
+	}
+	return amount.MinorUnitsWithExponent(exponent)
+}
+
+// stripeMoney converts an amount Stripe reported in its currency's units.
+func stripeMoney(amount int64, currency string) (domain.Money, error) {
+	exponent, ok := stripeCurrencyExponents[strings.ToUpper(currency)]
+	if !ok {
+		exponent = 2
+	}
+	return domain.MoneyFromMajor(float64(amount)/math.Pow10(exponent), currency)
 }
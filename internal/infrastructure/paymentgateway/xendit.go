@@ -2,10 +2,15 @@ package paymentgateway
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"payment-service/internal/domain"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/xendit/xendit-go"
 	"github.com/xendit/xendit-go/ewallet"
@@ -14,6 +19,24 @@ import (
 	"github.com/xendit/xendit-go/virtualaccount"
 )
 
+const (
+	// xenditInvoiceDuration and xenditVAExpiry are how long invoices and virtual
+	// accounts we create stay payable.
+	xenditInvoiceDuration = 24 * time.Hour
+	xenditVAExpiry        = 24 * time.Hour
+	// xenditQRCodeExpiry is how long we treat a QR code as payable. Xendit does
+	// not let us set or shorten it, so a QR code may still be paid after it.
+	xenditQRCodeExpiry = time.Hour
+)
+
+// xenditEWalletExpiry is how long Xendit leaves an e-wallet charge open for the
+// payer to authorise before it fails, per e-wallet.
+var xenditEWalletExpiry = map[string]time.Duration{
+	"OVO":     55 * time.Second,
+	"DANA":    30 * time.Minute,
+	"LINKAJA": 5 * time.Minute,
+}
+
 type XenditClient struct {
 	apiKey string
 }
@@ -24,13 +47,43 @@ func NewXenditClient() *XenditClient {
 	}
 }
 
+func (xc *XenditClient) SupportedMethods() []string {
+	return []string{"OVO", "DANA", "LINKAJA", "BCA", "BNI", "BRI", "QR", "DEFAULT"}
+}
+
+// ProcessPayment creates the payment and classifies the error of a failed call
+// as a *domain.GatewayError.
 func (xc *XenditClient) ProcessPayment(ctx context.Context, payment *domain.Payment) (string, error) {
+	reference, err := xc.createPayment(ctx, payment)
+	if err != nil {
+		return "", xenditGatewayError(err)
+	}
+	return reference, nil
+}
+
+func (xc *XenditClient) createPayment(ctx context.Context, payment *domain.Payment) (string, error) {
+	switch payment.PaymentMethod {
+	case "OVO", "DANA", "LINKAJA":
+		return xc.ChargeEWallet(ctx, payment)
+	case "BCA", "BNI", "BRI":
+		return xc.CreateVirtualAccount(ctx, payment)
+	case "QR":
+		return xc.CreateQRCode(ctx, payment)
+	case "DEFAULT":
+		return xc.CreateInvoice(ctx, payment)
+	default:
+		return "", fmt.Errorf("%w: %q for Xendit", domain.ErrUnsupportedPaymentMethod, payment.PaymentMethod)
+	}
+}
+
+func (xc *XenditClient) CreateInvoice(ctx context.Context, payment *domain.Payment) (string, error) {
 	xendit.Opt.SecretKey = xc.apiKey
 
 	data := invoice.CreateParams{
-		ExternalID: payment.PaymentID,
-		Amount:     payment.Amount,
-		Currency:   payment.Currency,
+		ExternalID:      payment.ExternalID,
+		Amount:          payment.Amount.Major(),
+		Currency:        payment.Amount.Currency,
+		InvoiceDuration: int(xenditInvoiceDuration.Seconds()),
 	}
 
 	log.Printf("Sending request to Xendit to create an invoice: %+v\n", data)
@@ -40,13 +93,89 @@ func (xc *XenditClient) ProcessPayment(ctx context.Context, payment *domain.Paym
 		return "", err
 	}
 
+	payment.ExpiresAt = time.Now().Add(xenditInvoiceDuration)
+	if createdInvoice.ExpiryDate != nil {
+		payment.ExpiresAt = *createdInvoice.ExpiryDate
+	}
+
 	log.Printf("Invoice created successfully with ID: %s\n", createdInvoice.ID)
 	return createdInvoice.ID, nil
 }
 
-func (xc *XenditClient) RefundPayment(ctx context.Context, paymentID string, amount float64) (string, error) {
-	// Implement refund logic with Xendit if available, as Xendit primarily supports invoice-based payments
-	return "", nil
+// xenditRefundReason is sent with every refund; Xendit only accepts a fixed set of reasons.
+const xenditRefundReason = "REQUESTED_BY_CUSTOMER"
+
+// xenditQRAPIVersion selects the QR Codes API version that exposes QR payment refunds.
+const xenditQRAPIVersion = "2022-07-31"
+
+type xenditRefund struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// RefundPayment refunds a captured Xendit payment. The Xendit SDK has no refund
+// bindings, so the refund endpoints are called through its API requester.
+// Invoices go through the Refunds API, e-wallet charges and QR payments through
+// their product endpoints. Closed virtual accounts cannot be refunded by Xendit.
+func (xc *XenditClient) RefundPayment(ctx context.Context, payment *domain.Payment, amount domain.Money) (*domain.GatewayRefund, error) {
+	xendit.Opt.SecretKey = xc.apiKey
+
+	var url string
+	var body map[string]interface{}
+	header := http.Header{}
+
+	switch strings.TrimPrefix(payment.PaymentMethod, "XEN-") {
+	case "DEFAULT":
+		url = fmt.Sprintf("%s/refunds", xendit.Opt.XenditURL)
+		body = map[string]interface{}{
+			"invoice_id": payment.GatewayReference,
+			"amount":     amount.Major(),
+			"reason":     xenditRefundReason,
+		}
+	case "OVO", "DANA", "LINKAJA":
+		url = fmt.Sprintf("%s/ewallets/charges/%s/refunds", xendit.Opt.XenditURL, payment.GatewayReference)
+		body = map[string]interface{}{
+			"amount": amount.Major(),
+			"reason": xenditRefundReason,
+		}
+	case "QR":
+		if payment.QrPaymentID == "" {
+			return nil, fmt.Errorf("%w: QR code %s has no recorded payment", domain.ErrRefundNotSupported, payment.GatewayReference)
+		}
+		url = fmt.Sprintf("%s/qr_codes/payments/%s/refunds", xendit.Opt.XenditURL, payment.QrPaymentID)
+		header.Set("api-version", xenditQRAPIVersion)
+		body = map[string]interface{}{
+			"amount": amount.Major(),
+			"reason": xenditRefundReason,
+		}
+	case "BCA", "BNI", "BRI":
+		return nil, fmt.Errorf("%w: xendit closed virtual account payments cannot be refunded", domain.ErrRefundNotSupported)
+	default:
+		return nil, fmt.Errorf("%w: unsupported payment method %q for Xendit refunds", domain.ErrRefundNotSupported, payment.PaymentMethod)
+	}
+
+	var refund xenditRefund
+	log.Printf("Sending request to Xendit to refund payment %s: %+v\n", payment.PaymentID, body)
+	if xerr := xendit.GetAPIRequester().Call(ctx, http.MethodPost, url, xc.apiKey, header, body, &refund); xerr != nil {
+		log.Printf("Error refunding payment with Xendit: %v\n", xerr)
+		return nil, xerr
+	}
+
+	log.Printf("Refund created successfully with ID: %s, Status: %s\n", refund.ID, refund.Status)
+	return &domain.GatewayRefund{GatewayRefundID: refund.ID, Status: xenditRefundStatus(refund.Status)}, nil
+}
+
+// xenditRefundStatus maps the status of a Xendit refund to our refund status.
+// Xendit creates refunds as PENDING and reports the outcome by callback.
+func xenditRefundStatus(status string) domain.RefundStatus {
+	switch strings.ToUpper(status) {
+	case "SUCCEEDED":
+		return domain.RefundStatusSucceeded
+	case "FAILED":
+		return domain.RefundStatusFailed
+	default:
+		return domain.RefundStatusPending
+	}
 }
 
 func (xc *XenditClient) ChargeEWallet(ctx context.Context, payment *domain.Payment) (string, error) {
@@ -56,9 +185,9 @@ func (xc *XenditClient) ChargeEWallet(ctx context.Context, payment *domain.Payme
 		"success_redirect_url": "https://arnatech.id",
 	}
 	params := ewallet.CreateEWalletChargeParams{
-		ReferenceID:       payment.PaymentID,
-		Currency:          payment.Currency,
-		Amount:            payment.Amount,
+		ReferenceID:       payment.ExternalID,
+		Currency:          payment.Amount.Currency,
+		Amount:            payment.Amount.Major(),
 		CheckoutMethod:    payment.EwalletCheckoutMethod,
 		ChannelCode:       "ID_" + payment.PaymentMethod,
 		ChannelProperties: channelProperties,
@@ -71,6 +200,10 @@ func (xc *XenditClient) ChargeEWallet(ctx context.Context, payment *domain.Payme
 		return "", err
 	}
 
+	if expiry, ok := xenditEWalletExpiry[payment.PaymentMethod]; ok {
+		payment.ExpiresAt = time.Now().Add(expiry)
+	}
+
 	log.Printf("E-wallet charged successfully with ID: %s\n", charge.ID)
 	return charge.ID, nil
 }
@@ -81,12 +214,14 @@ func (xc *XenditClient) CreateVirtualAccount(ctx context.Context, payment *domai
 	// Remove the "XEN-" prefix from the payment method
 	bankCode := strings.TrimPrefix(payment.PaymentMethod, "XEN-")
 	trueValue := true
+	expiresAt := time.Now().Add(xenditVAExpiry)
 	params := virtualaccount.CreateFixedVAParams{
-		ExternalID:     payment.PaymentID,
+		ExternalID:     payment.ExternalID,
 		BankCode:       bankCode,       // Bank code, e.g., "BCA", "BNI", etc.
 		Name:           payment.UserID, // Assuming UserID is the name here
-		ExpectedAmount: payment.Amount,
+		ExpectedAmount: payment.Amount.Major(),
 		IsClosed:       &trueValue,
+		ExpirationDate: &expiresAt,
 	}
 
 	log.Printf("Sending request to Xendit to create virtual account: %+v\n", params)
@@ -96,6 +231,11 @@ func (xc *XenditClient) CreateVirtualAccount(ctx context.Context, payment *domai
 		return "", err
 	}
 
+	payment.ExpiresAt = expiresAt
+	if va.ExpirationDate != nil {
+		payment.ExpiresAt = *va.ExpirationDate
+	}
+
 	log.Printf("Virtual account created successfully with ID: %s\n", va.ID)
 	return va.ID, nil
 }
@@ -104,8 +244,8 @@ func (xc *XenditClient) CreateQRCode(ctx context.Context, payment *domain.Paymen
 	xendit.Opt.SecretKey = xc.apiKey
 
 	params := qrcode.CreateQRCodeParams{
-		ExternalID:  payment.PaymentID,
-		Amount:      payment.Amount,
+		ExternalID:  payment.ExternalID,
+		Amount:      payment.Amount.Major(),
 		Type:        xendit.QRCodeType(payment.QrType),
 		CallbackURL: payment.QrCallbackURL,
 	}
@@ -118,7 +258,216 @@ func (xc *XenditClient) CreateQRCode(ctx context.Context, payment *domain.Paymen
 	}
 
 	payment.QrString = qrCode.QRString
+	payment.ExpiresAt = time.Now().Add(xenditQRCodeExpiry)
 
 	log.Printf("QR code created successfully with details: %+v\n", qrCode)
 	return qrCode.ID, nil
 }
+
+// ExpirePayment deactivates an expired invoice or virtual account so it can no
+// longer be paid. E-wallet charges and QR codes cannot be deactivated.
+func (xc *XenditClient) ExpirePayment(ctx context.Context, payment *domain.Payment) error {
+	xendit.Opt.SecretKey = xc.apiKey
+
+	switch payment.PaymentMethod {
+	case "DEFAULT":
+		log.Printf("Sending request to Xendit to expire invoice: %s\n", payment.GatewayReference)
+		if _, err := invoice.ExpireWithContext(ctx, &invoice.ExpireParams{ID: payment.GatewayReference}); err != nil {
+			log.Printf("Error expiring invoice with Xendit: %v\n", err)
+			return err
+		}
+	case "BCA", "BNI", "BRI":
+		// Moving the expiration date to now closes the virtual account.
+		now := time.Now()
+		log.Printf("Sending request to Xendit to close virtual account: %s\n", payment.GatewayReference)
+		if _, err := virtualaccount.UpdateFixedVAWithContext(ctx, &virtualaccount.UpdateFixedVAParams{ID: payment.GatewayReference, ExpirationDate: &now}); err != nil {
+			log.Printf("Error closing virtual account with Xendit: %v\n", err)
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %s payments at Xendit", domain.ErrExpiryNotSupported, payment.PaymentMethod)
+	}
+	return nil
+}
+
+// CancelPayment stops a pending payment from being paid: invoices are expired
+// and virtual accounts closed. Xendit only voids e-wallet charges that already
+// succeeded, and cannot deactivate QR codes, so neither can be cancelled.
+func (xc *XenditClient) CancelPayment(ctx context.Context, payment *domain.Payment) error {
+	switch payment.PaymentMethod {
+	case "DEFAULT", "BCA", "BNI", "BRI":
+		return xc.ExpirePayment(ctx, payment)
+	default:
+		return fmt.Errorf("%w: %s payments at Xendit", domain.ErrCancelNotSupported, payment.PaymentMethod)
+	}
+}
+
+// xenditTransactionPageSize is how many transactions are read per request to
+// the Transactions API, its maximum.
+const xenditTransactionPageSize = 50
+
+type xenditTransaction struct {
+	ID          string    `json:"id"`
+	ProductID   string    `json:"product_id"`
+	Status      string    `json:"status"`
+	ReferenceID string    `json:"reference_id"`
+	Currency    string    `json:"currency"`
+	Amount      float64   `json:"amount"`
+	Created     time.Time `json:"created"`
+}
+
+type xenditTransactionPage struct {
+	HasMore bool                `json:"has_more"`
+	Data    []xenditTransaction `json:"data"`
+}
+
+// xenditTransactionStatuses maps Transactions API statuses to payment statuses.
+// Reversed transactions were refunded and are left out.
+var xenditTransactionStatuses = map[string]domain.PaymentStatus{
+	"PENDING": domain.PaymentStatusPending,
+	"SUCCESS": domain.PaymentStatusPaid,
+	"FAILED":  domain.PaymentStatusFailed,
+	"VOIDED":  domain.PaymentStatusCancelled,
+}
+
+// ListTransactions reads the payments created between from and to from the
+// Transactions API, which covers every Xendit product. Its reference ID is the
+// external ID we created the payment with.
+func (xc *XenditClient) ListTransactions(ctx context.Context, from, to time.Time) ([]domain.GatewayTransaction, error) {
+	xendit.Opt.SecretKey = xc.apiKey
+
+	var transactions []domain.GatewayTransaction
+	afterID := ""
+	for {
+		query := url.Values{}
+		query.Set("types", "PAYMENT")
+		query.Set("created[gte]", from.UTC().Format(time.RFC3339))
+		query.Set("created[lt]", to.UTC().Format(time.RFC3339))
+		query.Set("limit", strconv.Itoa(xenditTransactionPageSize))
+		if afterID != "" {
+			query.Set("after_id", afterID)
+		}
+		endpoint := fmt.Sprintf("%s/transactions?%s", xendit.Opt.XenditURL, query.Encode())
+
+		var page xenditTransactionPage
+		if xerr := xendit.GetAPIRequester().Call(ctx, http.MethodGet, endpoint, xc.apiKey, http.Header{}, nil, &page); xerr != nil {
+			log.Printf("Error listing transactions with Xendit: %v\n", xerr)
+			return nil, xerr
+		}
+
+		for _, transaction := range page.Data {
+			status, ok := xenditTransactionStatuses[transaction.Status]
+			if !ok {
+				continue
+			}
+			amount, err := domain.MoneyFromMajor(transaction.Amount, transaction.Currency)
+			if err != nil {
+				return nil, fmt.Errorf("xendit transaction %s: %w", transaction.ID, err)
+			}
+			transactions = append(transactions, domain.GatewayTransaction{
+				Gateway:          domain.GatewayXendit,
+				ExternalID:       transaction.ReferenceID,
+				GatewayReference: transaction.ProductID,
+				Amount:           amount,
+				Status:           status,
+				CreatedAt:        transaction.Created,
+			})
+		}
+
+		if !page.HasMore || len(page.Data) == 0 {
+			return transactions, nil
+		}
+		afterID = page.Data[len(page.Data)-1].ID
+	}
+}
+
+// xenditInvoiceStatuses maps invoice statuses to payment statuses.
+var xenditInvoiceStatuses = map[string]domain.PaymentStatus{
+	"PENDING": domain.PaymentStatusPending,
+	"PAID":    domain.PaymentStatusPaid,
+	"SETTLED": domain.PaymentStatusPaid,
+	"EXPIRED": domain.PaymentStatusExpired,
+}
+
+// FindTransaction looks up the invoice or QR code created for payment by its
+// external ID. Xendit cannot look up virtual accounts or e-wallet charges by it.
+func (xc *XenditClient) FindTransaction(ctx context.Context, payment *domain.Payment) (*domain.GatewayTransaction, error) {
+	xendit.Opt.SecretKey = xc.apiKey
+
+	switch payment.PaymentMethod {
+	case "DEFAULT":
+		return xc.findInvoice(ctx, payment)
+	case "QR":
+		return xc.findQRCode(ctx, payment)
+	default:
+		return nil, fmt.Errorf("%w: %s payments at Xendit", domain.ErrTransactionLookupNotSupported, payment.PaymentMethod)
+	}
+}
+
+func (xc *XenditClient) findInvoice(ctx context.Context, payment *domain.Payment) (*domain.GatewayTransaction, error) {
+	endpoint := fmt.Sprintf("%s/v2/invoices?%s", xendit.Opt.XenditURL, url.Values{"external_id": {payment.ExternalID}}.Encode())
+
+	var invoices []xendit.Invoice
+	log.Printf("Sending request to Xendit to find invoice: %s\n", payment.ExternalID)
+	if xerr := xendit.GetAPIRequester().Call(ctx, http.MethodGet, endpoint, xc.apiKey, http.Header{}, nil, &invoices); xerr != nil {
+		log.Printf("Error finding invoice with Xendit: %v\n", xerr)
+		return nil, xerr
+	}
+	if len(invoices) == 0 {
+		return nil, domain.ErrGatewayTransactionNotFound
+	}
+
+	inv := invoices[0]
+	status, ok := xenditInvoiceStatuses[inv.Status]
+	if !ok {
+		status = domain.PaymentStatusPending
+	}
+	amount, err := domain.MoneyFromMajor(inv.Amount, inv.Currency)
+	if err != nil {
+		return nil, err
+	}
+	transaction := &domain.GatewayTransaction{
+		Gateway:          domain.GatewayXendit,
+		ExternalID:       inv.ExternalID,
+		GatewayReference: inv.ID,
+		Amount:           amount,
+		Status:           status,
+	}
+	if inv.Created != nil {
+		transaction.CreatedAt = *inv.Created
+	}
+	if inv.ExpiryDate != nil {
+		transaction.ExpiresAt = *inv.ExpiryDate
+	}
+	return transaction, nil
+}
+
+func (xc *XenditClient) findQRCode(ctx context.Context, payment *domain.Payment) (*domain.GatewayTransaction, error) {
+	log.Printf("Sending request to Xendit to find QR code: %s\n", payment.ExternalID)
+	qrCode, xerr := qrcode.GetQRCodeWithContext(ctx, &qrcode.GetQRCodeParams{ExternalID: payment.ExternalID})
+	if xerr != nil {
+		if xerr.Status == http.StatusNotFound {
+			return nil, domain.ErrGatewayTransactionNotFound
+		}
+		log.Printf("Error finding QR code with Xendit: %v\n", xerr)
+		return nil, xerr
+	}
+
+	amount, err := domain.MoneyFromMajor(qrCode.Amount, payment.Amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	// A QR code stays active after it is paid; payments are reported by callback.
+	transaction := &domain.GatewayTransaction{
+		Gateway:          domain.GatewayXendit,
+		ExternalID:       qrCode.ExternalID,
+		GatewayReference: qrCode.ID,
+		Amount:           amount,
+		Status:           domain.PaymentStatusPending,
+	}
+	if qrCode.Created != nil {
+		transaction.CreatedAt = *qrCode.Created
+		transaction.ExpiresAt = qrCode.Created.Add(xenditQRCodeExpiry)
+	}
+	return transaction, nil
+}
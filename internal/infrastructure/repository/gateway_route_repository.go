@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"payment-service/internal/domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoGatewayRouteRepository struct {
+	client *mongo.Client
+}
+
+func NewMongoGatewayRouteRepository(client *mongo.Client) domain.GatewayRouteRepository {
+	r := &MongoGatewayRouteRepository{
+		client: client,
+	}
+	r.ensureIndexes()
+	return r
+}
+
+func (r *MongoGatewayRouteRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := r.client.Database("paymentdb").Collection("gateway_routes")
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "paymentmethod", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Fatalf("failed to create gateway_routes indexes: %v", err)
+	}
+}
+
+// Upsert replaces the stored route of route.PaymentMethod.
+func (r *MongoGatewayRouteRepository) Upsert(ctx context.Context, route domain.GatewayRoute) error {
+	collection := r.client.Database("paymentdb").Collection("gateway_routes")
+	opts := options.Replace().SetUpsert(true)
+	_, err := collection.ReplaceOne(ctx, bson.M{"paymentmethod": route.PaymentMethod}, route, opts)
+	return err
+}
+
+func (r *MongoGatewayRouteRepository) FindAll(ctx context.Context) ([]domain.GatewayRoute, error) {
+	collection := r.client.Database("paymentdb").Collection("gateway_routes")
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var routes []domain.GatewayRoute
+	if err = cursor.All(ctx, &routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
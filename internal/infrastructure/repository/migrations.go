@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"payment-service/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MigratePaymentReferences backfills gatewayreference and externalid on payments
+// stored before they were kept apart from paymentid. Those payments had their
+// paymentid overwritten with the gateway's ID, which clients now know them by,
+// so it is kept and copied to gatewayreference. The ID originally sent to the
+// gateway was not stored and cannot be recovered.
+func MigratePaymentReferences(ctx context.Context, client *mongo.Client) (int64, error) {
+	collection := client.Database("paymentdb").Collection("payments")
+	filter := bson.M{"gatewayreference": bson.M{"$exists": false}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"gatewayreference": "$paymentid",
+			"externalid":       "",
+		}}},
+	}
+	result, err := collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// MigrateMoneyAmounts converts payments and refunds stored with floating-point
+// amounts in major units and a separate currency field to domain.Money in
+// minor units. Documents in a currency we do not support are left untouched
+// and logged.
+func MigrateMoneyAmounts(ctx context.Context, client *mongo.Client) (int64, error) {
+	var migrated int64
+	for _, name := range []string{"payments", "refunds"} {
+		collection := client.Database("paymentdb").Collection(name)
+		cursor, err := collection.Find(ctx, bson.M{"amount": bson.M{"$type": "number"}})
+		if err != nil {
+			return migrated, err
+		}
+
+		for cursor.Next(ctx) {
+			var doc bson.M
+			if err := cursor.Decode(&doc); err != nil {
+				cursor.Close(ctx)
+				return migrated, err
+			}
+
+			currency, _ := doc["currency"].(string)
+			if currency == "" {
+				currency = domain.DefaultCurrency
+			}
+			set := bson.M{}
+			ok := true
+			convert := func(field string, value interface{}) {
+				amount, isNumber := legacyAmount(value)
+				if !isNumber {
+					return
+				}
+				money, err := domain.MoneyFromMajor(amount, currency)
+				if err != nil {
+					ok = false
+					return
+				}
+				set[field] = money
+			}
+
+			convert("amount", doc["amount"])
+			convert("refundedamount", doc["refundedamount"])
+			if items, isArray := doc["items"].(bson.A); isArray {
+				for i, item := range items {
+					if fields, isDoc := item.(bson.M); isDoc {
+						convert(fmt.Sprintf("items.%d.price", i), fields["price"])
+					}
+				}
+			}
+			if !ok {
+				log.Printf("Skipping money migration of %s document %v: unsupported currency %q", name, doc["_id"], currency)
+				continue
+			}
+
+			_, err := collection.UpdateByID(ctx, doc["_id"], bson.M{"$set": set, "$unset": bson.M{"currency": ""}})
+			if err != nil {
+				cursor.Close(ctx)
+				return migrated, err
+			}
+			migrated++
+		}
+		if err := cursor.Err(); err != nil {
+			cursor.Close(ctx)
+			return migrated, err
+		}
+		cursor.Close(ctx)
+	}
+	return migrated, nil
+}
+
+// legacyPaymentStatuses maps the gateway statuses that payments stored before
+// the payment status enum carry, as the Xendit QR webhook wrote them as sent,
+// to the status they stand for.
+var legacyPaymentStatuses = map[string]domain.PaymentStatus{
+	"ACTIVE":    domain.PaymentStatusPending,
+	"PENDING":   domain.PaymentStatusPending,
+	"COMPLETED": domain.PaymentStatusPaid,
+	"SUCCEEDED": domain.PaymentStatusPaid,
+	"SUCCESS":   domain.PaymentStatusPaid,
+	"SETTLED":   domain.PaymentStatusPaid,
+	"PAID":      domain.PaymentStatusPaid,
+	"EXPIRED":   domain.PaymentStatusExpired,
+	"FAILED":    domain.PaymentStatusFailed,
+	"VOIDED":    domain.PaymentStatusFailed,
+}
+
+// MigrateLegacyStatuses rewrites the statuses of payments stored with a raw
+// gateway status, in any casing, to the payment status it stands for. Status
+// updates only match documents in a known status, so until then such payments
+// could not be refunded, expired or otherwise moved on.
+func MigrateLegacyStatuses(ctx context.Context, client *mongo.Client) (int64, error) {
+	collection := client.Database("paymentdb").Collection("payments")
+	var migrated int64
+	for legacy, status := range legacyPaymentStatuses {
+		filter := bson.M{"status": bson.M{
+			"$regex":   "^" + legacy + "$",
+			"$options": "i",
+			"$ne":      status,
+		}}
+		update := bson.M{"$set": bson.M{"status": status}}
+		result, err := collection.UpdateMany(ctx, filter, update)
+		if err != nil {
+			return migrated, err
+		}
+		migrated += result.ModifiedCount
+	}
+	return migrated, nil
+}
+
+// legacyAmount reads a numeric BSON value written by the float-based schema.
+func legacyAmount(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
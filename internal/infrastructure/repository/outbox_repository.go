@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"payment-service/internal/domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Outbox events are inserted by the payment and refund repositories in the
+// transaction of the change they describe, so an event is stored if and only
+// if its change is. Transactions need MongoDB to run as a replica set.
+
+type MongoOutboxRepository struct {
+	client *mongo.Client
+}
+
+func NewMongoOutboxRepository(client *mongo.Client) domain.OutboxRepository {
+	r := &MongoOutboxRepository{
+		client: client,
+	}
+	r.ensureIndexes()
+	return r
+}
+
+func (r *MongoOutboxRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := r.client.Database("paymentdb").Collection("outbox")
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "eventid", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextattemptat", Value: 1}}},
+		{Keys: bson.D{{Key: "paymentid", Value: 1}, {Key: "createdat", Value: 1}}},
+	})
+	if err != nil {
+		log.Fatalf("failed to create outbox indexes: %v", err)
+	}
+}
+
+func (r *MongoOutboxRepository) ClaimPending(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.OutboxEvent, error) {
+	collection := r.client.Database("paymentdb").Collection("outbox")
+	filter := bson.M{
+		"status":        domain.OutboxEventStatusPending,
+		"nextattemptat": bson.M{"$lte": now},
+	}
+	update := bson.M{"$set": bson.M{"nextattemptat": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "createdat", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var events []domain.OutboxEvent
+	for len(events) < limit {
+		var event domain.OutboxEvent
+		err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&event)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (r *MongoOutboxRepository) MarkPublished(ctx context.Context, eventID string) error {
+	collection := r.client.Database("paymentdb").Collection("outbox")
+	_, err := collection.UpdateOne(ctx, bson.M{"eventid": eventID}, bson.M{
+		"$set": bson.M{"status": domain.OutboxEventStatusPublished, "publishedat": time.Now(), "lasterror": ""},
+		"$inc": bson.M{"attempts": 1},
+	})
+	return err
+}
+
+func (r *MongoOutboxRepository) MarkFailed(ctx context.Context, eventID string, cause error, nextAttemptAt time.Time) error {
+	collection := r.client.Database("paymentdb").Collection("outbox")
+	_, err := collection.UpdateOne(ctx, bson.M{"eventid": eventID}, bson.M{
+		"$set": bson.M{"lasterror": cause.Error(), "nextattemptat": nextAttemptAt},
+		"$inc": bson.M{"attempts": 1},
+	})
+	return err
+}
+
+// withTransaction runs fn in a transaction. fn must use the context it is
+// given for every operation that belongs to the transaction.
+func withTransaction(ctx context.Context, client *mongo.Client, fn func(ctx mongo.SessionContext) error) error {
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+	return err
+}
+
+// insertOutboxEvent stores event for the relay to publish.
+func insertOutboxEvent(ctx context.Context, client *mongo.Client, event domain.OutboxEvent) error {
+	collection := client.Database("paymentdb").Collection("outbox")
+	_, err := collection.InsertOne(ctx, event)
+	return err
+}
@@ -2,11 +2,14 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"log"
 	"payment-service/internal/domain"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoPaymentRepository struct {
@@ -14,23 +17,85 @@ type MongoPaymentRepository struct {
 }
 
 func NewMongoPaymentRepository(client *mongo.Client) domain.PaymentRepository {
-	return &MongoPaymentRepository{
+	r := &MongoPaymentRepository{
 		client: client,
 	}
+	r.ensureIndexes()
+	return r
+}
+
+func (r *MongoPaymentRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := r.client.Database("paymentdb").Collection("payments")
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "idempotencykey", Value: 1}},
+		// Payments stored before idempotency keys existed have no key and must not collide.
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"idempotencykey": bson.M{"$type": "string"}}),
+	})
+	if err != nil {
+		log.Fatalf("failed to create payments idempotency index: %v", err)
+	}
+
+	_, err = collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "paymentid", Value: 1}}},
+		{Keys: bson.D{{Key: "externalid", Value: 1}}},
+		{Keys: bson.D{{Key: "gatewayreference", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expiresat", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdat", Value: 1}}},
+		{Keys: bson.D{{Key: "gateway", Value: 1}, {Key: "createdat", Value: 1}}},
+	})
+	if err != nil {
+		log.Fatalf("failed to create payments lookup indexes: %v", err)
+	}
 }
 
 func (r *MongoPaymentRepository) Save(ctx context.Context, payment *domain.Payment) error {
 	collection := r.client.Database("paymentdb").Collection("payments")
 	payment.CreatedAt = time.Now()
 	payment.UpdatedAt = time.Now()
-	_, err := collection.InsertOne(ctx, payment)
+	err := withTransaction(ctx, r.client, func(ctx mongo.SessionContext) error {
+		if _, err := collection.InsertOne(ctx, payment); err != nil {
+			return err
+		}
+		// Initiated payments are announced once the gateway has accepted them.
+		if eventType, ok := domain.PaymentStatusEvent("", payment.Status); ok {
+			return insertOutboxEvent(ctx, r.client, domain.NewPaymentEvent(eventType, payment))
+		}
+		return nil
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrDuplicatePayment
+	}
 	return err
 }
 
 func (r *MongoPaymentRepository) FindByID(ctx context.Context, paymentID string) (*domain.Payment, error) {
+	return r.findOne(ctx, bson.M{"paymentid": paymentID})
+}
+
+func (r *MongoPaymentRepository) FindByIdempotencyKey(ctx context.Context, key string) (*domain.Payment, error) {
+	return r.findOne(ctx, bson.M{"idempotencykey": key})
+}
+
+func (r *MongoPaymentRepository) FindByExternalID(ctx context.Context, externalID string) (*domain.Payment, error) {
+	return r.findOne(ctx, bson.M{"externalid": externalID})
+}
+
+func (r *MongoPaymentRepository) FindByGatewayReference(ctx context.Context, gatewayReference string) (*domain.Payment, error) {
+	return r.findOne(ctx, bson.M{"gatewayreference": gatewayReference})
+}
+
+func (r *MongoPaymentRepository) findOne(ctx context.Context, filter bson.M) (*domain.Payment, error) {
 	collection := r.client.Database("paymentdb").Collection("payments")
 	var payment domain.Payment
-	err := collection.FindOne(ctx, bson.M{"paymentid": paymentID}).Decode(&payment)
+	err := collection.FindOne(ctx, filter).Decode(&payment)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrPaymentNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -67,8 +132,292 @@ func (r *MongoPaymentRepository) FindByUserID(ctx context.Context, userID string
 	return payments, int(count), nil
 }
 
-func (r *MongoPaymentRepository) UpdateStatus(ctx context.Context, paymentID, status string) error {
+// UpdateStatus moves a payment to status. The update only matches documents
+// whose stored status may transition to the new one, so a concurrent or
+// replayed update cannot move a payment backwards. Statuses other services are
+// told about are recorded in the outbox in the same transaction.
+func (r *MongoPaymentRepository) UpdateStatus(ctx context.Context, paymentID string, status domain.PaymentStatus) error {
+	return r.updateStatus(ctx, paymentID, status, bson.M{})
+}
+
+func (r *MongoPaymentRepository) Submit(ctx context.Context, payment *domain.Payment) error {
+	return r.updateStatus(ctx, payment.PaymentID, domain.PaymentStatusPending, bson.M{
+		"gateway":          payment.Gateway,
+		"gatewayreference": payment.GatewayReference,
+		"qrstring":         payment.QrString,
+		"expiresat":        payment.ExpiresAt,
+		"attempts":         payment.Attempts,
+	})
+}
+
+func (r *MongoPaymentRepository) Reject(ctx context.Context, payment *domain.Payment) error {
+	return r.updateStatus(ctx, payment.PaymentID, domain.PaymentStatusFailed, bson.M{"attempts": payment.Attempts})
+}
+
+func (r *MongoPaymentRepository) RecordAttempts(ctx context.Context, payment *domain.Payment) error {
+	collection := r.client.Database("paymentdb").Collection("payments")
+	update := bson.M{"$set": bson.M{"gateway": payment.Gateway, "attempts": payment.Attempts, "updatedat": time.Now()}}
+	_, err := collection.UpdateOne(ctx, bson.M{"paymentid": payment.PaymentID}, update)
+	return err
+}
+
+func (r *MongoPaymentRepository) Cancel(ctx context.Context, paymentID, reason string) error {
+	return r.updateStatus(ctx, paymentID, domain.PaymentStatusCancelled, bson.M{"cancellationreason": reason})
+}
+
+// updateStatus moves a payment to status as UpdateStatus does, setting fields
+// along with it.
+func (r *MongoPaymentRepository) updateStatus(ctx context.Context, paymentID string, status domain.PaymentStatus, fields bson.M) error {
+	collection := r.client.Database("paymentdb").Collection("payments")
+	filter := bson.M{
+		"paymentid": paymentID,
+		"status":    bson.M{"$in": domain.StatusesTransitioningTo(status)},
+	}
+	fields["status"] = status
+	fields["updatedat"] = time.Now()
+	update := bson.M{"$set": fields}
+	err := withTransaction(ctx, r.client, func(ctx mongo.SessionContext) error {
+		// The status moved from decides the event, e.g. whether paid is a new payment.
+		var previous domain.Payment
+		err := collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&previous)
+		if err != nil {
+			return err
+		}
+		eventType, ok := domain.PaymentStatusEvent(previous.Status, status)
+		if !ok {
+			return nil
+		}
+		var payment domain.Payment
+		if err := collection.FindOne(ctx, bson.M{"paymentid": paymentID}).Decode(&payment); err != nil {
+			return err
+		}
+		return insertOutboxEvent(ctx, r.client, domain.NewPaymentEvent(eventType, &payment))
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		payment, err := r.FindByID(ctx, paymentID)
+		if err != nil {
+			return err
+		}
+		return &domain.StatusTransitionError{From: payment.Status, To: status}
+	}
+	return err
+}
+
+func (r *MongoPaymentRepository) FindExpired(ctx context.Context, now time.Time, limit int) ([]domain.Payment, error) {
+	collection := r.client.Database("paymentdb").Collection("payments")
+	filter := bson.M{
+		"status": domain.PaymentStatusPending,
+		// Payments that never expire store the zero time.
+		"expiresat": bson.M{"$gt": time.Time{}, "$lte": now},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "expiresat", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var payments []domain.Payment
+	if err = cursor.All(ctx, &payments); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+func (r *MongoPaymentRepository) FindInitiated(ctx context.Context, before time.Time, limit int) ([]domain.Payment, error) {
+	collection := r.client.Database("paymentdb").Collection("payments")
+	filter := bson.M{
+		"status":              domain.PaymentStatusInitiated,
+		"createdat":           bson.M{"$lte": before},
+		"needsreconciliation": bson.M{"$ne": true},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdat", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var payments []domain.Payment
+	if err = cursor.All(ctx, &payments); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+func (r *MongoPaymentRepository) FlagForReconciliation(ctx context.Context, paymentID string) error {
+	collection := r.client.Database("paymentdb").Collection("payments")
+	filter := bson.M{"paymentid": paymentID, "status": domain.PaymentStatusInitiated}
+	_, err := collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"needsreconciliation": true, "updatedat": time.Now()}})
+	return err
+}
+
+func (r *MongoPaymentRepository) FindByGatewayCreatedBetween(ctx context.Context, gateway string, from, to time.Time) ([]domain.Payment, error) {
+	collection := r.client.Database("paymentdb").Collection("payments")
+	filter := bson.M{
+		"gateway":   gateway,
+		"createdat": bson.M{"$gte": from, "$lt": to},
+	}
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var payments []domain.Payment
+	if err = cursor.All(ctx, &payments); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+func (r *MongoPaymentRepository) UpdateQrPaymentID(ctx context.Context, paymentID, qrPaymentID string) error {
+	collection := r.client.Database("paymentdb").Collection("payments")
+	_, err := collection.UpdateOne(ctx, bson.M{"paymentid": paymentID}, bson.M{"$set": bson.M{"qrpaymentid": qrPaymentID, "updatedat": time.Now()}})
+	return err
+}
+
+// refundableStatuses are the statuses of payments that may be refunded.
+var refundableStatuses = []domain.PaymentStatus{domain.PaymentStatusPaid, domain.PaymentStatusPartiallyRefunded}
+
+// ReserveRefund only matches a payment that is still refundable, so one
+// disputed, cancelled or failed since the caller read it gets no reservation.
+func (r *MongoPaymentRepository) ReserveRefund(ctx context.Context, paymentID string, amount domain.Money) (domain.Money, error) {
+	collection := r.client.Database("paymentdb").Collection("payments")
+	filter := bson.M{
+		"paymentid":       paymentID,
+		"status":          bson.M{"$in": refundableStatuses},
+		"amount.currency": amount.Currency,
+		"$expr": bson.M{"$lte": bson.A{
+			bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$refundedamount.minorunits", 0}}, amount.MinorUnits}},
+			"$amount.minorunits",
+		}},
+	}
+	update := bson.M{
+		"$inc": bson.M{"refundedamount.minorunits": amount.MinorUnits},
+		"$set": bson.M{"refundedamount.currency": amount.Currency, "updatedat": time.Now()},
+	}
+	var payment domain.Payment
+	err := collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&payment)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		payment, err := r.FindByID(ctx, paymentID)
+		if err != nil {
+			return domain.Money{}, err
+		}
+		for _, status := range refundableStatuses {
+			if payment.Status == status {
+				return domain.Money{}, domain.ErrRefundExceedsCaptured
+			}
+		}
+		return domain.Money{}, &domain.StatusTransitionError{From: payment.Status, To: domain.PaymentStatusRefunded}
+	}
+	if err != nil {
+		return domain.Money{}, err
+	}
+	return payment.RefundedAmount, nil
+}
+
+func (r *MongoPaymentRepository) ReleaseRefund(ctx context.Context, paymentID string, amount domain.Money) error {
 	collection := r.client.Database("paymentdb").Collection("payments")
-	_, err := collection.UpdateOne(ctx, bson.M{"paymentid": paymentID}, bson.M{"$set": bson.M{"status": status, "updatedat": time.Now()}})
+	_, err := collection.UpdateOne(ctx, bson.M{"paymentid": paymentID}, bson.M{
+		"$inc": bson.M{"refundedamount.minorunits": -amount.MinorUnits},
+		"$set": bson.M{"updatedat": time.Now()},
+	})
 	return err
 }
+
+// UpdateRefundStatus derives the status from the refunded amount read in the
+// same transaction, so refunds finishing out of order cannot leave the status
+// behind the amount: a reservation changing it in between aborts and retries
+// the transaction.
+func (r *MongoPaymentRepository) UpdateRefundStatus(ctx context.Context, paymentID string) (*domain.Payment, error) {
+	var payment *domain.Payment
+	err := withTransaction(ctx, r.client, func(ctx mongo.SessionContext) error {
+		var err error
+		payment, err = r.applyRefundStatus(ctx, paymentID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// CompleteRefund records a refund as succeeded and moves its payment to the
+// status the refunded amount calls for in one transaction, along with the
+// RefundIssued event of a refund that newly succeeded, which so carries the
+// payment's status after the refund.
+func (r *MongoPaymentRepository) CompleteRefund(ctx context.Context, refund *domain.Refund) (*domain.Payment, error) {
+	refunds := r.client.Database("paymentdb").Collection("refunds")
+	refund.Status = domain.RefundStatusSucceeded
+	refund.UpdatedAt = time.Now()
+	update := bson.M{"$set": bson.M{
+		"gatewayrefundid": refund.GatewayRefundID,
+		"status":          refund.Status,
+		"updatedat":       refund.UpdatedAt,
+	}}
+
+	var payment *domain.Payment
+	err := withTransaction(ctx, r.client, func(ctx mongo.SessionContext) error {
+		var previous domain.Refund
+		err := refunds.FindOneAndUpdate(ctx, bson.M{"refundid": refund.RefundID}, update).Decode(&previous)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return domain.ErrRefundNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		payment, err = r.applyRefundStatus(ctx, refund.PaymentID)
+		if err != nil {
+			return err
+		}
+		if previous.Status == domain.RefundStatusSucceeded {
+			return nil
+		}
+		return insertOutboxEvent(ctx, r.client, domain.NewRefundIssuedEvent(payment, refund))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// applyRefundStatus moves a payment to the status its refunded amount calls
+// for within the transaction of ctx, and returns it.
+func (r *MongoPaymentRepository) applyRefundStatus(ctx mongo.SessionContext, paymentID string) (*domain.Payment, error) {
+	collection := r.client.Database("paymentdb").Collection("payments")
+	var payment domain.Payment
+	err := collection.FindOne(ctx, bson.M{"paymentid": paymentID}).Decode(&payment)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	status := payment.RefundStatus()
+	if status == payment.Status {
+		return &payment, nil
+	}
+	if !payment.Status.CanTransitionTo(status) {
+		return nil, &domain.StatusTransitionError{From: payment.Status, To: status}
+	}
+
+	filter := bson.M{
+		"paymentid":                 paymentID,
+		"status":                    payment.Status,
+		"refundedamount.minorunits": payment.RefundedAmount.MinorUnits,
+	}
+	update := bson.M{"$set": bson.M{"status": status, "updatedat": time.Now()}}
+	var updated domain.Payment
+	err = collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err != nil {
+		return nil, err
+	}
+	if eventType, ok := domain.PaymentStatusEvent(payment.Status, status); ok {
+		if err := insertOutboxEvent(ctx, r.client, domain.NewPaymentEvent(eventType, &updated)); err != nil {
+			return nil, err
+		}
+	}
+	return &updated, nil
+}
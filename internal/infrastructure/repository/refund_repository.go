@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"payment-service/internal/domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoRefundRepository struct {
+	client *mongo.Client
+}
+
+func NewMongoRefundRepository(client *mongo.Client) domain.RefundRepository {
+	r := &MongoRefundRepository{
+		client: client,
+	}
+	r.ensureIndexes()
+	return r
+}
+
+func (r *MongoRefundRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := r.client.Database("paymentdb").Collection("refunds")
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "refundid", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "paymentid", Value: 1}, {Key: "createdat", Value: 1}}},
+		{Keys: bson.D{{Key: "gatewayrefundid", Value: 1}}},
+	})
+	if err != nil {
+		log.Fatalf("failed to create refunds indexes: %v", err)
+	}
+}
+
+func (r *MongoRefundRepository) Save(ctx context.Context, refund *domain.Refund) error {
+	collection := r.client.Database("paymentdb").Collection("refunds")
+	refund.CreatedAt = time.Now()
+	refund.UpdatedAt = time.Now()
+	_, err := collection.InsertOne(ctx, refund)
+	return err
+}
+
+// Update stores the outcome of a refund. Succeeded refunds are recorded by
+// MongoPaymentRepository.CompleteRefund along with their payment's status.
+func (r *MongoRefundRepository) Update(ctx context.Context, refund *domain.Refund) error {
+	collection := r.client.Database("paymentdb").Collection("refunds")
+	refund.UpdatedAt = time.Now()
+	update := bson.M{"$set": bson.M{
+		"gatewayrefundid": refund.GatewayRefundID,
+		"status":          refund.Status,
+		"failurereason":   refund.FailureReason,
+		"updatedat":       refund.UpdatedAt,
+	}}
+	result, err := collection.UpdateOne(ctx, bson.M{"refundid": refund.RefundID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrRefundNotFound
+	}
+	return nil
+}
+
+func (r *MongoRefundRepository) FindByPaymentID(ctx context.Context, paymentID string) ([]domain.Refund, error) {
+	collection := r.client.Database("paymentdb").Collection("refunds")
+	opts := options.Find().SetSort(bson.D{{Key: "createdat", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{"paymentid": paymentID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var refunds []domain.Refund
+	if err = cursor.All(ctx, &refunds); err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+func (r *MongoRefundRepository) FindByGatewayRefundID(ctx context.Context, gateway, gatewayRefundID string) (*domain.Refund, error) {
+	collection := r.client.Database("paymentdb").Collection("refunds")
+	var refund domain.Refund
+	err := collection.FindOne(ctx, bson.M{"gateway": gateway, "gatewayrefundid": gatewayRefundID}).Decode(&refund)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrRefundNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
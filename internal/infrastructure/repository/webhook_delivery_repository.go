@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"payment-service/internal/domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoWebhookDeliveryRepository struct {
+	client *mongo.Client
+}
+
+func NewMongoWebhookDeliveryRepository(client *mongo.Client) domain.WebhookDeliveryRepository {
+	r := &MongoWebhookDeliveryRepository{
+		client: client,
+	}
+	r.ensureIndexes()
+	return r
+}
+
+func (r *MongoWebhookDeliveryRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := r.client.Database("paymentdb").Collection("webhook_deliveries")
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "deliveryid", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextattemptat", Value: 1}}},
+		{Keys: bson.D{{Key: "agent", Value: 1}, {Key: "createdat", Value: -1}}},
+		{Keys: bson.D{{Key: "paymentid", Value: 1}, {Key: "createdat", Value: -1}}},
+	})
+	if err != nil {
+		log.Fatalf("failed to create webhook_deliveries indexes: %v", err)
+	}
+}
+
+func (r *MongoWebhookDeliveryRepository) Save(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	collection := r.client.Database("paymentdb").Collection("webhook_deliveries")
+	delivery.CreatedAt = time.Now()
+	delivery.UpdatedAt = time.Now()
+	_, err := collection.InsertOne(ctx, delivery)
+	return err
+}
+
+func (r *MongoWebhookDeliveryRepository) Update(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	collection := r.client.Database("paymentdb").Collection("webhook_deliveries")
+	delivery.UpdatedAt = time.Now()
+	_, err := collection.UpdateOne(ctx, bson.M{"deliveryid": delivery.DeliveryID}, bson.M{"$set": bson.M{
+		"status":           delivery.Status,
+		"attempts":         delivery.Attempts,
+		"lasterror":        delivery.LastError,
+		"lastresponsecode": delivery.LastResponseCode,
+		"nextattemptat":    delivery.NextAttemptAt,
+		"deliveredat":      delivery.DeliveredAt,
+		"updatedat":        delivery.UpdatedAt,
+	}})
+	return err
+}
+
+func (r *MongoWebhookDeliveryRepository) FindByID(ctx context.Context, deliveryID string) (*domain.WebhookDelivery, error) {
+	collection := r.client.Database("paymentdb").Collection("webhook_deliveries")
+	var delivery domain.WebhookDelivery
+	err := collection.FindOne(ctx, bson.M{"deliveryid": deliveryID}).Decode(&delivery)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *MongoWebhookDeliveryRepository) Find(ctx context.Context, filter domain.WebhookDeliveryFilter, page, pageSize int) ([]domain.WebhookDelivery, int, error) {
+	collection := r.client.Database("paymentdb").Collection("webhook_deliveries")
+	query := bson.M{}
+	if filter.Agent != "" {
+		query["agent"] = filter.Agent
+	}
+	if filter.PaymentID != "" {
+		query["paymentid"] = filter.PaymentID
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}})
+	if page > 0 && pageSize > 0 {
+		opts.SetSkip(int64((page - 1) * pageSize)).SetLimit(int64(pageSize))
+	}
+	cursor, err := collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var deliveries []domain.WebhookDelivery
+	if err = cursor.All(ctx, &deliveries); err != nil {
+		return nil, 0, err
+	}
+
+	count, err := collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	return deliveries, int(count), nil
+}
+
+func (r *MongoWebhookDeliveryRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.WebhookDelivery, error) {
+	collection := r.client.Database("paymentdb").Collection("webhook_deliveries")
+	filter := bson.M{
+		"status":        domain.WebhookDeliveryStatusPending,
+		"nextattemptat": bson.M{"$lte": now},
+	}
+	update := bson.M{"$set": bson.M{"nextattemptat": now.Add(lease), "updatedat": now}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "nextattemptat", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var deliveries []domain.WebhookDelivery
+	for len(deliveries) < limit {
+		var delivery domain.WebhookDelivery
+		err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
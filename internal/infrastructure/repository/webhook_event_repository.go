@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"payment-service/internal/domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoWebhookEventRepository struct {
+	client *mongo.Client
+}
+
+func NewMongoWebhookEventRepository(client *mongo.Client) domain.WebhookEventRepository {
+	r := &MongoWebhookEventRepository{
+		client: client,
+	}
+	r.ensureIndexes()
+	return r
+}
+
+func (r *MongoWebhookEventRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := r.client.Database("paymentdb").Collection("webhook_events")
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// A redelivered webhook must not be stored, and processed, twice.
+		{Keys: bson.D{{Key: "provider", Value: 1}, {Key: "webhookid", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "receivedat", Value: 1}}},
+	})
+	if err != nil {
+		log.Fatalf("failed to create webhook_events indexes: %v", err)
+	}
+}
+
+func (r *MongoWebhookEventRepository) Save(ctx context.Context, event *domain.WebhookEvent) error {
+	collection := r.client.Database("paymentdb").Collection("webhook_events")
+	event.ReceivedAt = time.Now()
+	event.UpdatedAt = time.Now()
+	_, err := collection.InsertOne(ctx, event)
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrDuplicateWebhookEvent
+	}
+	return err
+}
+
+func (r *MongoWebhookEventRepository) Find(ctx context.Context, provider, webhookID string) (*domain.WebhookEvent, error) {
+	collection := r.client.Database("paymentdb").Collection("webhook_events")
+	var event domain.WebhookEvent
+	err := collection.FindOne(ctx, bson.M{"provider": provider, "webhookid": webhookID}).Decode(&event)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrWebhookEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *MongoWebhookEventRepository) Claim(ctx context.Context, provider, webhookID string, now time.Time, lease time.Duration) (*domain.WebhookEvent, error) {
+	collection := r.client.Database("paymentdb").Collection("webhook_events")
+	filter := bson.M{
+		"provider":  provider,
+		"webhookid": webhookID,
+		"$or": bson.A{
+			bson.M{"status": domain.WebhookEventStatusFailed},
+			bson.M{
+				"status":     bson.M{"$in": bson.A{domain.WebhookEventStatusReceived, domain.WebhookEventStatusProcessing}},
+				"leaseuntil": bson.M{"$not": bson.M{"$gt": now}},
+			},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{"status": domain.WebhookEventStatusProcessing, "leaseuntil": now.Add(lease), "updatedat": now},
+		"$inc": bson.M{"attempts": 1},
+	}
+	var event domain.WebhookEvent
+	err := collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&event)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrWebhookEventInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *MongoWebhookEventRepository) Update(ctx context.Context, event *domain.WebhookEvent) error {
+	collection := r.client.Database("paymentdb").Collection("webhook_events")
+	event.UpdatedAt = time.Now()
+	_, err := collection.UpdateOne(ctx, bson.M{"provider": event.Provider, "webhookid": event.WebhookID}, bson.M{"$set": bson.M{
+		"status":      event.Status,
+		"result":      event.Result,
+		"error":       event.Error,
+		"attempts":    event.Attempts,
+		"processedat": event.ProcessedAt,
+		"updatedat":   event.UpdatedAt,
+	}})
+	return err
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"payment-service/internal/domain"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoWebhookSubscriptionRepository struct {
+	client *mongo.Client
+}
+
+func NewMongoWebhookSubscriptionRepository(client *mongo.Client) domain.WebhookSubscriptionRepository {
+	r := &MongoWebhookSubscriptionRepository{
+		client: client,
+	}
+	r.ensureIndexes()
+	return r
+}
+
+func (r *MongoWebhookSubscriptionRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := r.client.Database("paymentdb").Collection("webhook_subscriptions")
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "agent", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "subscriptionid", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		log.Fatalf("failed to create webhook_subscriptions indexes: %v", err)
+	}
+}
+
+// Upsert replaces the agent's subscription, keeping its ID and creation time
+// if it already had one.
+func (r *MongoWebhookSubscriptionRepository) Upsert(ctx context.Context, subscription *domain.WebhookSubscription) error {
+	collection := r.client.Database("paymentdb").Collection("webhook_subscriptions")
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"callbackurl": subscription.CallbackURL,
+			"secret":      subscription.Secret,
+			"updatedat":   now,
+		},
+		"$setOnInsert": bson.M{
+			"subscriptionid": subscription.SubscriptionID,
+			"createdat":      now,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	return collection.FindOneAndUpdate(ctx, bson.M{"agent": subscription.Agent}, update, opts).Decode(subscription)
+}
+
+func (r *MongoWebhookSubscriptionRepository) FindByAgent(ctx context.Context, agent string) (*domain.WebhookSubscription, error) {
+	return r.findOne(ctx, bson.M{"agent": agent})
+}
+
+func (r *MongoWebhookSubscriptionRepository) FindByID(ctx context.Context, subscriptionID string) (*domain.WebhookSubscription, error) {
+	return r.findOne(ctx, bson.M{"subscriptionid": subscriptionID})
+}
+
+func (r *MongoWebhookSubscriptionRepository) findOne(ctx context.Context, filter bson.M) (*domain.WebhookSubscription, error) {
+	collection := r.client.Database("paymentdb").Collection("webhook_subscriptions")
+	var subscription domain.WebhookSubscription
+	err := collection.FindOne(ctx, filter).Decode(&subscription)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrWebhookSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
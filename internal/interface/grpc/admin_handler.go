@@ -0,0 +1,114 @@
+package grpc
+
+import (
+	"context"
+	"log"
+	"payment-service/api/proto"
+	"payment-service/internal/domain"
+	"payment-service/internal/usecase"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type PaymentAdminHandler struct {
+	proto.UnimplementedPaymentAdminServiceServer
+	reconciliation usecase.ReconciliationUseCase
+	breakers       *usecase.GatewayBreakers
+}
+
+func NewPaymentAdminHandler(reconciliation usecase.ReconciliationUseCase, breakers *usecase.GatewayBreakers) *PaymentAdminHandler {
+	return &PaymentAdminHandler{reconciliation: reconciliation, breakers: breakers}
+}
+
+func (h *PaymentAdminHandler) ReconcilePayments(ctx context.Context, req *proto.ReconcilePaymentsRequest) (*proto.ReconcilePaymentsResponse, error) {
+	log.Printf("Received ReconcilePayments request: Gateway=%s, From=%s, To=%s, AutoHeal=%t", req.Gateway, req.From.AsTime(), req.To.AsTime(), req.AutoHeal)
+
+	if req.From == nil || req.To == nil {
+		return nil, status.Error(codes.InvalidArgument, "from and to are required")
+	}
+	from, to := req.From.AsTime(), req.To.AsTime()
+	if !from.Before(to) {
+		return nil, status.Error(codes.InvalidArgument, "from must be before to")
+	}
+
+	var reports []domain.ReconciliationReport
+	if req.Gateway == "" {
+		var err error
+		reports, err = h.reconciliation.ReconcileAll(ctx, from, to, req.AutoHeal)
+		if err != nil {
+			log.Printf("Error reconciling payments: %v", err)
+			return nil, toStatusError(err)
+		}
+	} else {
+		report, err := h.reconciliation.Reconcile(ctx, req.Gateway, from, to, req.AutoHeal)
+		if err != nil {
+			log.Printf("Error reconciling payments: %v", err)
+			return nil, toStatusError(err)
+		}
+		reports = append(reports, *report)
+	}
+
+	response := &proto.ReconcilePaymentsResponse{}
+	for _, report := range reports {
+		response.Reports = append(response.Reports, toProtoReconciliationReport(report))
+	}
+
+	log.Printf("Payments reconciled successfully: %d reports", len(reports))
+	return response, nil
+}
+
+func (h *PaymentAdminHandler) ListCircuitBreakers(ctx context.Context, req *proto.ListCircuitBreakersRequest) (*proto.ListCircuitBreakersResponse, error) {
+	log.Printf("Received ListCircuitBreakers request: Gateway=%s", req.Gateway)
+
+	response := &proto.ListCircuitBreakersResponse{}
+	for _, breaker := range h.breakers.Statuses() {
+		if req.Gateway != "" && breaker.Gateway != usecase.NormalizeGatewayCode(req.Gateway) {
+			continue
+		}
+		response.CircuitBreakers = append(response.CircuitBreakers, &proto.CircuitBreaker{
+			Gateway:             breaker.Gateway,
+			PaymentMethod:       breaker.PaymentMethod,
+			State:               string(breaker.State),
+			ConsecutiveFailures: int32(breaker.ConsecutiveFailures),
+			Successes:           breaker.Successes,
+			Failures:            breaker.Failures,
+			AverageLatencyMs:    breaker.AverageLatency.Milliseconds(),
+			LastError:           breaker.LastError,
+			LastFailureAt:       optionalTimestamp(breaker.LastFailureAt),
+			OpenedAt:            optionalTimestamp(breaker.OpenedAt),
+		})
+	}
+
+	log.Printf("Circuit breakers listed successfully: %d breakers", len(response.CircuitBreakers))
+	return response, nil
+}
+
+func toProtoReconciliationReport(report domain.ReconciliationReport) *proto.ReconciliationReport {
+	discrepancies := make([]*proto.Discrepancy, len(report.Discrepancies))
+	for i, discrepancy := range report.Discrepancies {
+		discrepancies[i] = &proto.Discrepancy{
+			Type:              string(discrepancy.Type),
+			Gateway:           discrepancy.Gateway,
+			ExternalId:        discrepancy.ExternalID,
+			PaymentId:         discrepancy.PaymentID,
+			LocalAmountMinor:  discrepancy.LocalAmount.MinorUnits,
+			RemoteAmountMinor: discrepancy.RemoteAmount.MinorUnits,
+			LocalCurrency:     discrepancy.LocalAmount.Currency,
+			RemoteCurrency:    discrepancy.RemoteAmount.Currency,
+			LocalStatus:       string(discrepancy.LocalStatus),
+			RemoteStatus:      string(discrepancy.RemoteStatus),
+			Healed:            discrepancy.Healed,
+			HealError:         discrepancy.HealError,
+		}
+	}
+	return &proto.ReconciliationReport{
+		Gateway:       report.Gateway,
+		From:          timestamppb.New(report.From),
+		To:            timestamppb.New(report.To),
+		Matched:       int32(report.Matched),
+		Discrepancies: discrepancies,
+		GeneratedAt:   timestamppb.New(report.GeneratedAt),
+	}
+}
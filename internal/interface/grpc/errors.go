@@ -0,0 +1,67 @@
+package grpc
+
+import (
+	"errors"
+	"payment-service/internal/domain"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatusError maps domain errors to gRPC status errors so clients can tell
+// a rejected request apart from an internal failure.
+func toStatusError(err error) error {
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		return validationStatusError(validationErr)
+	}
+
+	switch {
+	case errors.Is(err, domain.ErrPaymentNotFound),
+		errors.Is(err, domain.ErrRefundNotFound),
+		errors.Is(err, domain.ErrWebhookSubscriptionNotFound),
+		errors.Is(err, domain.ErrWebhookDeliveryNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrUnsupportedPaymentMethod),
+		errors.Is(err, domain.ErrInvalidRefundAmount),
+		errors.Is(err, domain.ErrRefundExceedsCaptured),
+		errors.Is(err, domain.ErrUnsupportedCurrency),
+		errors.Is(err, domain.ErrCurrencyMismatch),
+		errors.Is(err, domain.ErrPrecisionLoss),
+		errors.Is(err, domain.ErrInvalidCallbackURL):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrIdempotencyConflict):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, domain.ErrPaymentInitiated):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, domain.ErrGatewayUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, domain.ErrInvalidStatusTransition),
+		errors.Is(err, domain.ErrRefundNotSupported),
+		errors.Is(err, domain.ErrCancelNotSupported),
+		errors.Is(err, domain.ErrReconciliationNotSupported),
+		errors.Is(err, domain.ErrUnsupportedGateway):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return err
+	}
+}
+
+// validationStatusError returns an InvalidArgument status carrying each field
+// violation as a BadRequest detail.
+func validationStatusError(err *domain.ValidationError) error {
+	badRequest := &errdetails.BadRequest{}
+	for _, violation := range err.Violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       violation.Field,
+			Description: violation.Description,
+		})
+	}
+
+	st, detailErr := status.New(codes.InvalidArgument, err.Error()).WithDetails(badRequest)
+	if detailErr != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return st.Err()
+}
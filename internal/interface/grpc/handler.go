@@ -8,6 +8,7 @@ import (
 	"payment-service/api/proto"
 	"payment-service/internal/domain"
 	"payment-service/internal/usecase"
+	"time"
 
 	"github.com/google/uuid"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -23,13 +24,17 @@ func NewPaymentHandler(useCase usecase.PaymentUseCase) *PaymentHandler {
 }
 
 func (h *PaymentHandler) ProcessPayment(ctx context.Context, req *proto.ProcessPaymentRequest) (*proto.ProcessPaymentResponse, error) {
-	log.Printf("Received ProcessPayment request: UserId=%s, Amount=%.2f, InvoiceNumber=%s", req.UserId, req.Amount, req.InvoiceNumber)
+	log.Printf("Received ProcessPayment request: UserId=%s, AmountMinor=%d, Amount=%.2f, InvoiceNumber=%s", req.UserId, req.AmountMinor, req.Amount, req.InvoiceNumber)
 
 	// Validate required fields
 	if req.UserId == "" {
 		return nil, errors.New("user_id is required")
 	}
-	if req.Amount == 0 {
+	amount, err := requestMoney(req.AmountMinor, req.Amount, req.Currency)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	if amount.IsZero() {
 		return nil, errors.New("amount is required")
 	}
 	if req.InvoiceNumber == "" {
@@ -44,18 +49,24 @@ func (h *PaymentHandler) ProcessPayment(ctx context.Context, req *proto.ProcessP
 
 	items := make([]domain.Item, len(req.Items))
 	for i, item := range req.Items {
+		price, err := requestMoney(item.PriceMinor, item.Price, amount.Currency)
+		if err != nil {
+			return nil, toStatusError(err)
+		}
 		items[i] = domain.Item{
 			ItemName: item.ItemName,
 			Quantity: int(item.Quantity),
-			Price:    item.Price,
+			Price:    price,
 		}
 	}
 
 	payment := &domain.Payment{
 		PaymentID:             uuid.New().String(),
 		UserID:                req.UserId,
-		Amount:                req.Amount,
-		Currency:              req.Currency,
+		Amount:                amount,
+		Tax:                   domain.Money{MinorUnits: req.TaxMinor, Currency: amount.Currency},
+		Shipping:              domain.Money{MinorUnits: req.ShippingMinor, Currency: amount.Currency},
+		Discount:              domain.Money{MinorUnits: req.DiscountMinor, Currency: amount.Currency},
 		PaymentMethod:         req.PaymentMethod,
 		PhoneNumber:           req.PhoneNumber,
 		EwalletCheckoutMethod: req.EwalletCheckoutMethod,
@@ -64,35 +75,106 @@ func (h *PaymentHandler) ProcessPayment(ctx context.Context, req *proto.ProcessP
 		InvoiceNumber:         req.InvoiceNumber,
 		Agent:                 req.Agent,
 		Items:                 items,
+		IdempotencyKey:        req.IdempotencyKey,
 	}
 
 	result, err := h.useCase.ProcessPayment(ctx, payment)
 	if err != nil {
 		log.Printf("Error processing payment: %v", err)
-		return nil, err
+		return nil, toStatusError(err)
 	}
 
 	log.Printf("Payment processed successfully: PaymentId=%s, Status=%s", result.PaymentID, result.Status)
 	return &proto.ProcessPaymentResponse{
 		PaymentId:     result.PaymentID,
-		Status:        result.Status,
+		Status:        string(result.Status),
 		PaymentMethod: result.PaymentMethod,
 		QrString:      result.QrString,
+		ExpiresAt:     optionalTimestamp(result.ExpiresAt),
 	}, nil
 }
 
 func (h *PaymentHandler) RefundPayment(ctx context.Context, req *proto.RefundPaymentRequest) (*proto.RefundPaymentResponse, error) {
-	log.Printf("Received RefundPayment request: PaymentId=%s, Amount=%.2f", req.PaymentId, req.Amount)
+	log.Printf("Received RefundPayment request: PaymentId=%s, AmountMinor=%d, Amount=%.2f", req.PaymentId, req.AmountMinor, req.Amount)
+
+	amount := domain.Money{MinorUnits: req.AmountMinor}
+	if req.AmountMinor == 0 && req.Amount != 0 {
+		// The deprecated decimal amount is in the payment's currency, which only the stored payment knows.
+		payment, err := h.useCase.GetPayment(ctx, req.PaymentId)
+		if err != nil {
+			return nil, toStatusError(err)
+		}
+		amount, err = domain.MoneyFromMajor(req.Amount, payment.Amount.Currency)
+		if err != nil {
+			return nil, toStatusError(err)
+		}
+	}
 
-	refundID, err := h.useCase.RefundPayment(ctx, req.PaymentId, req.Amount)
+	refund, paymentStatus, err := h.useCase.RefundPayment(ctx, req.PaymentId, amount, req.Reason)
 	if err != nil {
 		log.Printf("Error refunding payment: %v", err)
-		return nil, err
+		return nil, toStatusError(err)
+	}
+
+	log.Printf("Payment refunded successfully: RefundId=%s", refund.RefundID)
+
+	return &proto.RefundPaymentResponse{
+		RefundId:     refund.RefundID,
+		Status:       string(paymentStatus),
+		RefundStatus: string(refund.Status),
+		Amount:       refund.Amount.Major(),
+		AmountMinor:  refund.Amount.MinorUnits,
+		Currency:     refund.Amount.Currency,
+	}, nil
+}
+
+func (h *PaymentHandler) CancelPayment(ctx context.Context, req *proto.CancelPaymentRequest) (*proto.CancelPaymentResponse, error) {
+	log.Printf("Received CancelPayment request: PaymentId=%s, Reason=%s", req.PaymentId, req.Reason)
+
+	payment, err := h.useCase.CancelPayment(ctx, req.PaymentId, req.Reason)
+	if err != nil {
+		log.Printf("Error cancelling payment: %v", err)
+		return nil, toStatusError(err)
+	}
+
+	log.Printf("Payment cancelled successfully: PaymentId=%s", payment.PaymentID)
+
+	return &proto.CancelPaymentResponse{
+		PaymentId: payment.PaymentID,
+		Status:    string(payment.Status),
+	}, nil
+}
+
+func (h *PaymentHandler) ListRefunds(ctx context.Context, req *proto.ListRefundsRequest) (*proto.ListRefundsResponse, error) {
+	log.Printf("Received ListRefunds request: PaymentId=%s", req.PaymentId)
+
+	refunds, err := h.useCase.ListRefunds(ctx, req.PaymentId)
+	if err != nil {
+		log.Printf("Error listing refunds: %v", err)
+		return nil, toStatusError(err)
+	}
+
+	response := &proto.ListRefundsResponse{}
+	for _, refund := range refunds {
+		response.Refunds = append(response.Refunds, &proto.Refund{
+			RefundId:        refund.RefundID,
+			PaymentId:       refund.PaymentID,
+			Amount:          refund.Amount.Major(),
+			AmountMinor:     refund.Amount.MinorUnits,
+			Currency:        refund.Amount.Currency,
+			Reason:          refund.Reason,
+			Gateway:         refund.Gateway,
+			GatewayRefundId: refund.GatewayRefundID,
+			Status:          string(refund.Status),
+			FailureReason:   refund.FailureReason,
+			CreatedAt:       timestamppb.New(refund.CreatedAt),
+			UpdatedAt:       timestamppb.New(refund.UpdatedAt),
+		})
 	}
 
-	log.Printf("Payment refunded successfully: RefundId=%s", refundID)
+	log.Printf("Refunds listed successfully for PaymentId=%s", req.PaymentId)
 
-	return &proto.RefundPaymentResponse{RefundId: refundID, Status: "refunded"}, nil
+	return response, nil
 }
 
 func (h *PaymentHandler) GetPaymentStatus(ctx context.Context, req *proto.GetPaymentStatusRequest) (*proto.GetPaymentStatusResponse, error) {
@@ -101,14 +183,14 @@ func (h *PaymentHandler) GetPaymentStatus(ctx context.Context, req *proto.GetPay
 	payment, err := h.useCase.GetPayment(ctx, req.PaymentId)
 	if err != nil {
 		log.Printf("Error getting payment status: %v", err)
-		return nil, err
+		return nil, toStatusError(err)
 	}
 
 	log.Printf("Payment status retrieved successfully: PaymentId=%s, Status=%s", payment.PaymentID, payment.Status)
 
 	return &proto.GetPaymentStatusResponse{
 		PaymentId:    payment.PaymentID,
-		Status:       payment.Status,
+		Status:       string(payment.Status),
 		ErrorMessage: "",
 	}, nil
 }
@@ -119,20 +201,22 @@ func (h *PaymentHandler) ListPayments(ctx context.Context, req *proto.ListPaymen
 	payments, total, err := h.useCase.ListPayments(ctx, req.UserId, int(req.Page), int(req.PageSize))
 	if err != nil {
 		log.Printf("Error listing payments: %v", err)
-		return nil, err
+		return nil, toStatusError(err)
 	}
 
 	response := &proto.ListPaymentsResponse{TotalCount: int32(total)}
 	for _, payment := range payments {
 		response.Payments = append(response.Payments, &proto.Payment{
-			PaymentId:     payment.PaymentID,
-			UserId:        payment.UserID,
-			Amount:        payment.Amount,
-			Currency:      payment.Currency,
-			PaymentMethod: payment.PaymentMethod,
-			Gateway:       payment.Gateway,
-			Status:        payment.Status,
-			CreatedAt:     timestamppb.New(payment.CreatedAt),
+			PaymentId:           payment.PaymentID,
+			UserId:              payment.UserID,
+			Amount:              payment.Amount.Major(),
+			AmountMinor:         payment.Amount.MinorUnits,
+			RefundedAmountMinor: payment.RefundedAmount.MinorUnits,
+			Currency:            payment.Amount.Currency,
+			PaymentMethod:       payment.PaymentMethod,
+			Gateway:             payment.Gateway,
+			Status:              string(payment.Status),
+			CreatedAt:           timestamppb.New(payment.CreatedAt),
 		})
 	}
 
@@ -147,15 +231,28 @@ func (h *PaymentHandler) GetPaymentDetail(ctx context.Context, req *proto.GetPay
 	payment, err := h.useCase.GetPayment(ctx, req.PaymentId)
 	if err != nil {
 		log.Printf("Error getting payment detail: %v", err)
-		return nil, err
+		return nil, toStatusError(err)
 	}
 
 	items := make([]*proto.Item, len(payment.Items))
 	for i, item := range payment.Items {
 		items[i] = &proto.Item{
-			ItemName: item.ItemName,
-			Quantity: int32(item.Quantity),
-			Price:    item.Price,
+			ItemName:   item.ItemName,
+			Quantity:   int32(item.Quantity),
+			Price:      item.Price.Major(),
+			PriceMinor: item.Price.MinorUnits,
+		}
+	}
+
+	attempts := make([]*proto.PaymentAttempt, len(payment.Attempts))
+	for i, attempt := range payment.Attempts {
+		attempts[i] = &proto.PaymentAttempt{
+			Gateway:     attempt.Gateway,
+			Succeeded:   attempt.Succeeded,
+			Error:       attempt.Error,
+			Retryable:   attempt.Retryable,
+			AttemptedAt: timestamppb.New(attempt.AttemptedAt),
+			LatencyMs:   attempt.Latency.Milliseconds(),
 		}
 	}
 
@@ -163,9 +260,11 @@ func (h *PaymentHandler) GetPaymentDetail(ctx context.Context, req *proto.GetPay
 	return &proto.GetPaymentDetailResponse{
 		PaymentId:             payment.PaymentID,
 		UserId:                payment.UserID,
-		Amount:                payment.Amount,
-		Currency:              payment.Currency,
-		Status:                payment.Status,
+		Amount:                payment.Amount.Major(),
+		AmountMinor:           payment.Amount.MinorUnits,
+		RefundedAmountMinor:   payment.RefundedAmount.MinorUnits,
+		Currency:              payment.Amount.Currency,
+		Status:                string(payment.Status),
 		CreatedAt:             timestamppb.New(payment.CreatedAt),
 		UpdatedAt:             timestamppb.New(payment.UpdatedAt),
 		PaymentMethod:         payment.PaymentMethod,
@@ -178,5 +277,37 @@ func (h *PaymentHandler) GetPaymentDetail(ctx context.Context, req *proto.GetPay
 		InvoiceNumber:         payment.InvoiceNumber,
 		Agent:                 payment.Agent,
 		Items:                 items,
+		ExternalId:            payment.ExternalID,
+		GatewayReference:      payment.GatewayReference,
+		ExpiresAt:             optionalTimestamp(payment.ExpiresAt),
+		CancellationReason:    payment.CancellationReason,
+		Attempts:              attempts,
 	}, nil
 }
+
+func (h *PaymentHandler) WatchPaymentStatus(req *proto.WatchPaymentStatusRequest, stream proto.PaymentService_WatchPaymentStatusServer) error {
+	log.Printf("Received WatchPaymentStatus request: PaymentId=%s", req.PaymentId)
+
+	err := h.useCase.WatchPaymentStatus(stream.Context(), req.PaymentId, func(payment *domain.Payment) error {
+		return stream.Send(&proto.PaymentStatusUpdate{
+			PaymentId: payment.PaymentID,
+			Status:    string(payment.Status),
+			UpdatedAt: timestamppb.New(payment.UpdatedAt),
+		})
+	})
+	if err != nil && stream.Context().Err() == nil {
+		log.Printf("Error watching payment status: %v", err)
+		return toStatusError(err)
+	}
+
+	log.Printf("Stopped watching payment status: PaymentId=%s", req.PaymentId)
+	return nil
+}
+
+// optionalTimestamp converts t, leaving the field unset for the zero time.
+func optionalTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
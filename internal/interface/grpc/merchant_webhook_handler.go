@@ -0,0 +1,102 @@
+package grpc
+
+import (
+	"context"
+	"log"
+	"payment-service/api/proto"
+	"payment-service/internal/domain"
+	"payment-service/internal/usecase"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type MerchantWebhookHandler struct {
+	proto.UnimplementedMerchantWebhookServiceServer
+	useCase usecase.MerchantWebhookUseCase
+}
+
+func NewMerchantWebhookHandler(useCase usecase.MerchantWebhookUseCase) *MerchantWebhookHandler {
+	return &MerchantWebhookHandler{useCase: useCase}
+}
+
+func (h *MerchantWebhookHandler) RegisterWebhook(ctx context.Context, req *proto.RegisterWebhookRequest) (*proto.RegisterWebhookResponse, error) {
+	log.Printf("Received RegisterWebhook request: Agent=%s, CallbackUrl=%s", req.Agent, req.CallbackUrl)
+
+	if req.Agent == "" {
+		return nil, status.Error(codes.InvalidArgument, "agent is required")
+	}
+
+	subscription, err := h.useCase.RegisterWebhook(ctx, req.Agent, req.CallbackUrl)
+	if err != nil {
+		log.Printf("Error registering webhook: %v", err)
+		return nil, toStatusError(err)
+	}
+
+	log.Printf("Webhook registered successfully: Agent=%s, SubscriptionId=%s", subscription.Agent, subscription.SubscriptionID)
+
+	return &proto.RegisterWebhookResponse{
+		Subscription: &proto.WebhookSubscription{
+			SubscriptionId: subscription.SubscriptionID,
+			Agent:          subscription.Agent,
+			CallbackUrl:    subscription.CallbackURL,
+			CreatedAt:      timestamppb.New(subscription.CreatedAt),
+			UpdatedAt:      timestamppb.New(subscription.UpdatedAt),
+		},
+		Secret: subscription.Secret,
+	}, nil
+}
+
+func (h *MerchantWebhookHandler) ListWebhookDeliveries(ctx context.Context, req *proto.ListWebhookDeliveriesRequest) (*proto.ListWebhookDeliveriesResponse, error) {
+	log.Printf("Received ListWebhookDeliveries request: Agent=%s, PaymentId=%s, Status=%s, Page=%d, PageSize=%d", req.Agent, req.PaymentId, req.Status, req.Page, req.PageSize)
+
+	filter := domain.WebhookDeliveryFilter{
+		Agent:     req.Agent,
+		PaymentID: req.PaymentId,
+		Status:    domain.WebhookDeliveryStatus(req.Status),
+	}
+	deliveries, total, err := h.useCase.ListDeliveries(ctx, filter, int(req.Page), int(req.PageSize))
+	if err != nil {
+		log.Printf("Error listing webhook deliveries: %v", err)
+		return nil, toStatusError(err)
+	}
+
+	response := &proto.ListWebhookDeliveriesResponse{TotalCount: int32(total)}
+	for i := range deliveries {
+		response.Deliveries = append(response.Deliveries, toProtoWebhookDelivery(&deliveries[i]))
+	}
+	return response, nil
+}
+
+func (h *MerchantWebhookHandler) RedeliverWebhook(ctx context.Context, req *proto.RedeliverWebhookRequest) (*proto.RedeliverWebhookResponse, error) {
+	log.Printf("Received RedeliverWebhook request: DeliveryId=%s", req.DeliveryId)
+
+	delivery, err := h.useCase.Redeliver(ctx, req.DeliveryId)
+	if err != nil {
+		log.Printf("Error redelivering webhook: %v", err)
+		return nil, toStatusError(err)
+	}
+
+	log.Printf("Webhook scheduled for redelivery: DeliveryId=%s", delivery.DeliveryID)
+
+	return &proto.RedeliverWebhookResponse{Delivery: toProtoWebhookDelivery(delivery)}, nil
+}
+
+func toProtoWebhookDelivery(delivery *domain.WebhookDelivery) *proto.WebhookDelivery {
+	return &proto.WebhookDelivery{
+		DeliveryId:       delivery.DeliveryID,
+		SubscriptionId:   delivery.SubscriptionID,
+		Agent:            delivery.Agent,
+		PaymentId:        delivery.PaymentID,
+		EventType:        delivery.EventType,
+		Payload:          delivery.Payload,
+		Status:           string(delivery.Status),
+		Attempts:         int32(delivery.Attempts),
+		LastError:        delivery.LastError,
+		LastResponseCode: int32(delivery.LastResponseCode),
+		NextAttemptAt:    timestamppb.New(delivery.NextAttemptAt),
+		CreatedAt:        timestamppb.New(delivery.CreatedAt),
+		DeliveredAt:      optionalTimestamp(delivery.DeliveredAt),
+	}
+}
@@ -0,0 +1,15 @@
+package grpc
+
+import "payment-service/internal/domain"
+
+// requestMoney returns the exact amount carried by a request, preferring the
+// minor-unit field over the deprecated decimal one.
+func requestMoney(minorUnits int64, amount float64, currency string) (domain.Money, error) {
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
+	if minorUnits != 0 {
+		return domain.NewMoney(minorUnits, currency)
+	}
+	return domain.MoneyFromMajor(amount, currency)
+}
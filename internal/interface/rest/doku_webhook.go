@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"payment-service/internal/domain"
+	"strings"
+)
+
+// DokuSignatureVerifier checks the Signature header of a DOKU notification.
+type DokuSignatureVerifier interface {
+	VerifyNotification(clientID, requestID, timestamp, target string, body []byte, signature string) error
+}
+
+// DokuNotification takes DOKU payment notifications, signed like our requests
+// to DOKU over the Client-Id, Request-Id, Request-Timestamp, Request-Target and
+// body digest.
+func (c *PaymentHandler) DokuNotification(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	requestID := r.Header.Get("Request-Id")
+	if c.config.DokuVerifier == nil || requestID == "" {
+		log.Printf("rejected DOKU notification from %s: missing Request-Id or verifier", r.RemoteAddr)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	err = c.config.DokuVerifier.VerifyNotification(r.Header.Get("Client-Id"), requestID, r.Header.Get("Request-Timestamp"), r.URL.Path, body, r.Header.Get("Signature"))
+	if err != nil {
+		log.Printf("rejected DOKU notification %s from %s: %v", requestID, r.RemoteAddr, err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	// DOKU sends the same Request-Id with every retry of a notification.
+	c.serveWebhook(w, r, domain.GatewayDoku, requestID, body, func(body []byte) (webhookCallback, error) {
+		var payload domain.DokuNotification
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return webhookCallback{}, err
+		}
+		status := payload.Transaction.Status
+		return webhookCallback{
+			eventType: strings.ToLower(payload.Service.ID + "." + status),
+			apply: func(ctx context.Context) (string, error) {
+				paymentStatus, ok := dokuPaymentStatus(status)
+				if !ok {
+					log.Printf("ignored DOKU notification %s in status %s", requestID, status)
+					return "Ignored", nil
+				}
+				return c.useCase.ApplyPaymentNotification(ctx, domain.PaymentNotification{
+					Gateway:          domain.GatewayDoku,
+					ExternalID:       payload.Order.InvoiceNumber,
+					GatewayReference: payload.VirtualAccountInfo.VirtualAccountNumber,
+					Status:           paymentStatus,
+				})
+			},
+		}, nil
+	})
+}
+
+// dokuPaymentStatus maps a DOKU transaction status to our payment status.
+func dokuPaymentStatus(status string) (domain.PaymentStatus, bool) {
+	switch strings.ToUpper(status) {
+	case "SUCCESS":
+		return domain.PaymentStatusPaid, true
+	case "FAILED":
+		return domain.PaymentStatusFailed, true
+	case "EXPIRED":
+		return domain.PaymentStatusExpired, true
+	default:
+		return "", false
+	}
+}
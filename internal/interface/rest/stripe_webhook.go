@@ -0,0 +1,146 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"payment-service/internal/domain"
+
+	"github.com/stripe/stripe-go/v72"
+	"github.com/stripe/stripe-go/v72/webhook"
+)
+
+// stripeMaxBodyBytes bounds the webhook body read before its signature is checked.
+const stripeMaxBodyBytes = 64 << 10
+
+// StripeWebhook takes Stripe events signed with the endpoint secret in the
+// Stripe-Signature header.
+func (c *PaymentHandler) StripeWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, stripeMaxBodyBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event, err := webhook.ConstructEventWithTolerance(body, r.Header.Get("Stripe-Signature"), c.config.StripeEndpointSecret, c.config.StripeTolerance)
+	if err != nil {
+		log.Printf("rejected Stripe webhook from %s: %v", r.RemoteAddr, err)
+		http.Error(w, "invalid signature", http.StatusBadRequest)
+		return
+	}
+
+	c.serveWebhook(w, r, domain.GatewayStripe, event.ID, body, func([]byte) (webhookCallback, error) {
+		return c.stripeCallback(event)
+	})
+}
+
+// stripeCallback maps the Stripe events we act on to payment and refund
+// notifications. Other events are recorded and acknowledged.
+func (c *PaymentHandler) stripeCallback(event stripe.Event) (webhookCallback, error) {
+	callback := webhookCallback{
+		eventType: event.Type,
+		apply: func(context.Context) (string, error) {
+			log.Printf("ignored Stripe %s event %s", event.Type, event.ID)
+			return "Ignored", nil
+		},
+	}
+
+	switch event.Type {
+	case "payment_intent.succeeded", "payment_intent.canceled":
+		var intent stripe.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &intent); err != nil {
+			return webhookCallback{}, err
+		}
+		status := domain.PaymentStatusPaid
+		if event.Type == "payment_intent.canceled" {
+			status = domain.PaymentStatusFailed
+		}
+		callback.apply = c.stripePaymentNotification(domain.PaymentNotification{
+			ExternalID:       intent.Metadata["external_id"],
+			GatewayReference: intent.ID,
+			Status:           status,
+		})
+
+	case "payment_intent.payment_failed":
+		// A failed attempt returns the PaymentIntent to requires_payment_method,
+		// and the payer may still pay it, so the payment stays pending.
+		var intent stripe.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &intent); err != nil {
+			return webhookCallback{}, err
+		}
+		reason := ""
+		if intent.LastPaymentError != nil {
+			reason = intent.LastPaymentError.Msg
+		}
+		callback.apply = func(context.Context) (string, error) {
+			log.Printf("Stripe payment attempt on %s failed, payment left pending: %s", intent.ID, reason)
+			return "Recorded", nil
+		}
+
+	case "charge.dispute.created":
+		var dispute stripe.Dispute
+		if err := json.Unmarshal(event.Data.Raw, &dispute); err != nil {
+			return webhookCallback{}, err
+		}
+		if dispute.PaymentIntent == nil {
+			break
+		}
+		callback.apply = c.stripePaymentNotification(domain.PaymentNotification{
+			GatewayReference: dispute.PaymentIntent.ID,
+			Status:           domain.PaymentStatusDisputed,
+		})
+
+	case "charge.refunded":
+		var charge stripe.Charge
+		if err := json.Unmarshal(event.Data.Raw, &charge); err != nil {
+			return webhookCallback{}, err
+		}
+		if charge.Refunds == nil {
+			break
+		}
+		// Refunds are issued through RefundPayment, so each one is already in
+		// the ledger; one that is not yet there is retried by Stripe.
+		refunds := charge.Refunds.Data
+		callback.apply = func(ctx context.Context) (string, error) {
+			for _, refund := range refunds {
+				status, ok := stripeRefundStatus(refund.Status)
+				if !ok {
+					continue
+				}
+				_, err := c.useCase.ApplyRefundNotification(ctx, domain.RefundNotification{
+					Gateway:         domain.GatewayStripe,
+					GatewayRefundID: refund.ID,
+					Status:          status,
+					FailureReason:   string(refund.FailureReason),
+				})
+				if err != nil {
+					return "failed", err
+				}
+			}
+			return "Success", nil
+		}
+	}
+
+	return callback, nil
+}
+
+func (c *PaymentHandler) stripePaymentNotification(notification domain.PaymentNotification) func(ctx context.Context) (string, error) {
+	notification.Gateway = domain.GatewayStripe
+	return func(ctx context.Context) (string, error) {
+		return c.useCase.ApplyPaymentNotification(ctx, notification)
+	}
+}
+
+// stripeRefundStatus maps a Stripe refund status to our refund status.
+func stripeRefundStatus(status stripe.RefundStatus) (domain.RefundStatus, bool) {
+	switch status {
+	case stripe.RefundStatusSucceeded:
+		return domain.RefundStatusSucceeded, true
+	case stripe.RefundStatusFailed, stripe.RefundStatusCanceled:
+		return domain.RefundStatusFailed, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,181 @@
+package rest
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"payment-service/internal/domain"
+	"strings"
+)
+
+// xenditCallbackTokenHeader carries the verification token Xendit sends with
+// every callback; it is set per account in the Xendit dashboard.
+const xenditCallbackTokenHeader = "x-callback-token"
+
+// verifyXenditCallback reports whether r carries our Xendit callback token. An
+// unconfigured token rejects every callback rather than accepting them all.
+func (c *PaymentHandler) verifyXenditCallback(r *http.Request) bool {
+	token := r.Header.Get(xenditCallbackTokenHeader)
+	if c.config.XenditCallbackToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.config.XenditCallbackToken)) == 1
+}
+
+// serveXenditCallback verifies a Xendit callback and hands it to serveWebhook.
+func (c *PaymentHandler) serveXenditCallback(w http.ResponseWriter, r *http.Request, decode func(body []byte) (webhookCallback, error)) {
+	if !c.verifyXenditCallback(r) {
+		log.Printf("rejected Xendit callback from %s: invalid %s header", r.RemoteAddr, xenditCallbackTokenHeader)
+		http.Error(w, "invalid callback token", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Xendit sends the same webhook-id with every delivery of an event.
+	webhookID := r.Header.Get("webhook-id")
+	if webhookID == "" {
+		webhookID = bodyWebhookID(body)
+	}
+	c.serveWebhook(w, r, domain.GatewayXendit, webhookID, body, decode)
+}
+
+// XenditInvoiceCallback takes invoice paid, settled and expired callbacks.
+func (c *PaymentHandler) XenditInvoiceCallback(w http.ResponseWriter, r *http.Request) {
+	c.serveXenditCallback(w, r, func(body []byte) (webhookCallback, error) {
+		var payload domain.XenditInvoiceCallback
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return webhookCallback{}, err
+		}
+		return c.xenditPaymentCallback("invoice."+strings.ToLower(payload.Status), payload.Status, domain.PaymentNotification{
+			ExternalID:       payload.ExternalID,
+			GatewayReference: payload.ID,
+		}), nil
+	})
+}
+
+// XenditFVACallback takes fixed virtual account payment callbacks. Xendit only
+// calls back for completed payments, so they carry no status.
+func (c *PaymentHandler) XenditFVACallback(w http.ResponseWriter, r *http.Request) {
+	c.serveXenditCallback(w, r, func(body []byte) (webhookCallback, error) {
+		var payload domain.XenditFVAPaymentCallback
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return webhookCallback{}, err
+		}
+		return c.xenditPaymentCallback("fva.paid", "PAID", domain.PaymentNotification{
+			ExternalID:       payload.ExternalID,
+			GatewayReference: payload.CallbackVirtualAccountID,
+		}), nil
+	})
+}
+
+// XenditEWalletCallback takes e-wallet charge callbacks.
+func (c *PaymentHandler) XenditEWalletCallback(w http.ResponseWriter, r *http.Request) {
+	c.serveXenditCallback(w, r, func(body []byte) (webhookCallback, error) {
+		var payload domain.XenditEWalletChargeCallback
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return webhookCallback{}, err
+		}
+		return c.xenditPaymentCallback(payload.Event, payload.Data.Status, domain.PaymentNotification{
+			ExternalID:       payload.Data.ReferenceID,
+			GatewayReference: payload.Data.ID,
+		}), nil
+	})
+}
+
+// XenditQRCallback takes QR code payment callbacks.
+func (c *PaymentHandler) XenditQRCallback(w http.ResponseWriter, r *http.Request) {
+	c.serveXenditCallback(w, r, func(body []byte) (webhookCallback, error) {
+		var payload domain.QRCallbackRequest
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return webhookCallback{}, err
+		}
+		return c.xenditPaymentCallback(payload.Event, payload.Data.Status, domain.PaymentNotification{
+			ExternalID:       payload.Data.ReferenceID,
+			GatewayReference: payload.Data.QRID,
+			QrPaymentID:      payload.Data.ID,
+		}), nil
+	})
+}
+
+// XenditRefundCallback takes refund succeeded and failed callbacks.
+func (c *PaymentHandler) XenditRefundCallback(w http.ResponseWriter, r *http.Request) {
+	c.serveXenditCallback(w, r, func(body []byte) (webhookCallback, error) {
+		var payload domain.XenditRefundCallback
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return webhookCallback{}, err
+		}
+		return webhookCallback{
+			eventType: payload.Event,
+			apply: func(ctx context.Context) (string, error) {
+				status, ok := xenditRefundStatus(payload.Data.Status)
+				if !ok {
+					log.Printf("ignored Xendit refund %s in status %s", payload.Data.ID, payload.Data.Status)
+					return "Ignored", nil
+				}
+				return c.useCase.ApplyRefundNotification(ctx, domain.RefundNotification{
+					Gateway:         domain.GatewayXendit,
+					GatewayRefundID: payload.Data.ID,
+					Status:          status,
+					FailureReason:   payload.Data.FailureCode,
+				})
+			},
+		}, nil
+	})
+}
+
+// xenditPaymentCallback applies a Xendit payment callback reporting status
+// for the payment identified by notification.
+func (c *PaymentHandler) xenditPaymentCallback(eventType, status string, notification domain.PaymentNotification) webhookCallback {
+	return webhookCallback{
+		eventType: eventType,
+		apply: func(ctx context.Context) (string, error) {
+			paymentStatus, ok := xenditPaymentStatus(status)
+			if !ok {
+				// Intermediate statuses such as PENDING need no action, and Xendit
+				// would keep retrying a callback we reject.
+				log.Printf("ignored Xendit %s callback in status %s", eventType, status)
+				return "Ignored", nil
+			}
+			notification.Gateway = domain.GatewayXendit
+			notification.Status = paymentStatus
+			if paymentStatus != domain.PaymentStatusPaid {
+				notification.QrPaymentID = ""
+			}
+			return c.useCase.ApplyPaymentNotification(ctx, notification)
+		},
+	}
+}
+
+// xenditPaymentStatus maps a status reported by a Xendit callback to our payment status.
+func xenditPaymentStatus(status string) (domain.PaymentStatus, bool) {
+	switch strings.ToUpper(status) {
+	case "COMPLETED", "SUCCEEDED", "SETTLED", "PAID":
+		return domain.PaymentStatusPaid, true
+	case "EXPIRED":
+		return domain.PaymentStatusExpired, true
+	case "FAILED", "VOIDED":
+		return domain.PaymentStatusFailed, true
+	default:
+		return "", false
+	}
+}
+
+// xenditRefundStatus maps a status reported by a Xendit refund callback to our refund status.
+func xenditRefundStatus(status string) (domain.RefundStatus, bool) {
+	switch strings.ToUpper(status) {
+	case "SUCCEEDED":
+		return domain.RefundStatusSucceeded, true
+	case "FAILED":
+		return domain.RefundStatusFailed, true
+	default:
+		return "", false
+	}
+}
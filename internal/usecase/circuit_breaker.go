@@ -0,0 +1,217 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"payment-service/internal/domain"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CircuitBreakerConfig tunes when gateway circuit breakers open and close.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is how many failed or slow calls in a row open a breaker.
+	FailureThreshold int
+	// SlowCallThreshold is how long a call may take before it counts as failed,
+	// even if it succeeded.
+	SlowCallThreshold time.Duration
+	// OpenDuration is how long an open breaker rejects calls before it lets one
+	// through to probe the gateway.
+	OpenDuration time.Duration
+}
+
+var DefaultCircuitBreakerConfig = CircuitBreakerConfig{
+	FailureThreshold:  5,
+	SlowCallThreshold: 10 * time.Second,
+	OpenDuration:      30 * time.Second,
+}
+
+// latencySmoothing weighs each call in the average latency of a breaker.
+const latencySmoothing = 0.2
+
+type breakerKey struct {
+	gateway string
+	method  string
+}
+
+type circuitBreaker struct {
+	status domain.CircuitBreakerStatus
+	// probing is set while the call probing a half-open breaker is in flight.
+	probing bool
+}
+
+// allows reports whether the breaker lets a call through at now.
+func (br *circuitBreaker) allows(now time.Time, openDuration time.Duration) bool {
+	switch br.status.State {
+	case domain.CircuitOpen:
+		return !br.probing && !now.Before(br.status.OpenedAt.Add(openDuration))
+	case domain.CircuitHalfOpen:
+		return !br.probing
+	default:
+		return true
+	}
+}
+
+// GatewayBreakers holds a circuit breaker for every gateway and for every
+// payment method at each gateway, fed by the outcome and latency of the calls
+// creating payments. A call is let through only if both breakers allow it. The
+// gateway's breaker only counts failures of the whole gateway, such as
+// timeouts and server errors, so an outage of one method does not stop the others.
+type GatewayBreakers struct {
+	mu       sync.Mutex
+	config   CircuitBreakerConfig
+	breakers map[breakerKey]*circuitBreaker
+}
+
+func NewGatewayBreakers(config CircuitBreakerConfig) *GatewayBreakers {
+	return &GatewayBreakers{
+		config:   config,
+		breakers: make(map[breakerKey]*circuitBreaker),
+	}
+}
+
+// breakerKeys returns the keys of the gateway's breaker and of the method's breaker.
+func breakerKeys(gateway, method string) []breakerKey {
+	gateway = NormalizeGatewayCode(gateway)
+	return []breakerKey{{gateway: gateway}, {gateway: gateway, method: method}}
+}
+
+// Available reports whether a call to gateway for method would be let through.
+func (b *GatewayBreakers) Available(gateway, method string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	for _, key := range breakerKeys(gateway, method) {
+		if br, ok := b.breakers[key]; ok && !br.allows(now, b.config.OpenDuration) {
+			return false
+		}
+	}
+	return true
+}
+
+// Acquire lets a call to gateway for method through and returns the function
+// recording its outcome, or fails with ErrGatewayUnavailable if a breaker is
+// open. The first call after an open breaker's OpenDuration probes the gateway;
+// others are rejected until it completes.
+func (b *GatewayBreakers) Acquire(gateway, method string) (func(err error), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+
+	keys := breakerKeys(gateway, method)
+	breakers := make([]*circuitBreaker, len(keys))
+	for i, key := range keys {
+		br, ok := b.breakers[key]
+		if !ok {
+			br = &circuitBreaker{status: domain.CircuitBreakerStatus{
+				Gateway:       key.gateway,
+				PaymentMethod: key.method,
+				State:         domain.CircuitClosed,
+			}}
+			b.breakers[key] = br
+		}
+		if !br.allows(now, b.config.OpenDuration) {
+			return nil, fmt.Errorf("%w: circuit of %s open", domain.ErrGatewayUnavailable, breakerName(key))
+		}
+		breakers[i] = br
+	}
+	for _, br := range breakers {
+		if br.status.State != domain.CircuitClosed {
+			br.status.State = domain.CircuitHalfOpen
+			br.probing = true
+		}
+	}
+
+	return func(err error) {
+		b.record(breakers, err, time.Since(now))
+	}, nil
+}
+
+// record applies the outcome of a call to the breakers it went through.
+func (b *GatewayBreakers) record(breakers []*circuitBreaker, err error, latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+
+	// A call the caller gave up on says nothing about the gateway.
+	if errors.Is(err, context.Canceled) {
+		for _, br := range breakers {
+			br.probing = false
+		}
+		return
+	}
+
+	methodFailure, gatewayFailure := "", ""
+	switch {
+	// Declined and invalid payments say nothing about the gateway either.
+	case domain.IsRetryableGatewayError(err), domain.IsAmbiguousGatewayError(err):
+		methodFailure = err.Error()
+		if domain.IsGatewayWideError(err) {
+			gatewayFailure = methodFailure
+		}
+	case latency > b.config.SlowCallThreshold:
+		methodFailure = fmt.Sprintf("slow call: took %s", latency)
+	}
+
+	for _, br := range breakers {
+		br.probing = false
+		status := &br.status
+		failure := methodFailure
+		if status.PaymentMethod == "" {
+			failure = gatewayFailure
+		}
+		if status.AverageLatency == 0 {
+			status.AverageLatency = latency
+		} else {
+			status.AverageLatency += time.Duration(latencySmoothing * float64(latency-status.AverageLatency))
+		}
+
+		if failure == "" {
+			status.Successes++
+			status.ConsecutiveFailures = 0
+			if status.State != domain.CircuitClosed {
+				log.Printf("Circuit of %s closed", breakerName(breakerKey{status.Gateway, status.PaymentMethod}))
+				status.State = domain.CircuitClosed
+			}
+			continue
+		}
+
+		status.Failures++
+		status.ConsecutiveFailures++
+		status.LastError = failure
+		status.LastFailureAt = now
+		if status.State == domain.CircuitHalfOpen ||
+			(status.State == domain.CircuitClosed && status.ConsecutiveFailures >= b.config.FailureThreshold) {
+			log.Printf("Circuit of %s opened after %d failures: %s", breakerName(breakerKey{status.Gateway, status.PaymentMethod}), status.ConsecutiveFailures, failure)
+			status.State = domain.CircuitOpen
+			status.OpenedAt = now
+		}
+	}
+}
+
+// Statuses returns the state of every breaker, ordered by gateway and method.
+func (b *GatewayBreakers) Statuses() []domain.CircuitBreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	statuses := make([]domain.CircuitBreakerStatus, 0, len(b.breakers))
+	for _, br := range b.breakers {
+		statuses = append(statuses, br.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Gateway != statuses[j].Gateway {
+			return statuses[i].Gateway < statuses[j].Gateway
+		}
+		return statuses[i].PaymentMethod < statuses[j].PaymentMethod
+	})
+	return statuses
+}
+
+func breakerName(key breakerKey) string {
+	if key.method == "" {
+		return key.gateway
+	}
+	return key.gateway + " " + key.method
+}
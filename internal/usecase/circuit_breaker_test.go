@@ -0,0 +1,70 @@
+package usecase
+
+import (
+	"errors"
+	"payment-service/internal/domain"
+	"testing"
+	"time"
+)
+
+var testBreakerConfig = CircuitBreakerConfig{
+	FailureThreshold:  3,
+	SlowCallThreshold: time.Minute,
+	OpenDuration:      time.Minute,
+}
+
+// failCalls records n calls to gateway for method failing with err.
+func failCalls(t *testing.T, breakers *GatewayBreakers, gateway, method string, err error, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		done, acquireErr := breakers.Acquire(gateway, method)
+		if acquireErr != nil {
+			t.Fatalf("Acquire(%s, %s) error = %v", gateway, method, acquireErr)
+		}
+		done(err)
+	}
+}
+
+func TestGatewayBreakersMethodOutage(t *testing.T) {
+	breakers := NewGatewayBreakers(testBreakerConfig)
+	// Xendit answers, but cannot reach the e-wallet provider.
+	channelDown := &domain.GatewayError{Gateway: domain.GatewayXendit, Retryable: true, Err: errors.New("CHANNEL_UNAVAILABLE")}
+	failCalls(t, breakers, domain.GatewayXendit, "OVO", channelDown, testBreakerConfig.FailureThreshold)
+
+	if breakers.Available(domain.GatewayXendit, "OVO") {
+		t.Error("Available(XENDIT, OVO) = true after an OVO outage")
+	}
+	if !breakers.Available(domain.GatewayXendit, "BCA") {
+		t.Error("Available(XENDIT, BCA) = false after an OVO outage")
+	}
+	if _, err := breakers.Acquire(domain.GatewayXendit, "OVO"); !errors.Is(err, domain.ErrGatewayUnavailable) {
+		t.Errorf("Acquire(XENDIT, OVO) error = %v, want %v", err, domain.ErrGatewayUnavailable)
+	}
+}
+
+func TestGatewayBreakersGatewayOutage(t *testing.T) {
+	breakers := NewGatewayBreakers(testBreakerConfig)
+	unreachable := &domain.GatewayError{Gateway: domain.GatewayXendit, Retryable: true, GatewayWide: true, Err: errors.New("dial tcp: connection refused")}
+	// Failures of the gateway count across its payment methods.
+	failCalls(t, breakers, domain.GatewayXendit, "OVO", unreachable, 2)
+	failCalls(t, breakers, domain.GatewayXendit, "BCA", unreachable, 1)
+
+	for _, method := range []string{"OVO", "BCA", "QR"} {
+		if breakers.Available(domain.GatewayXendit, method) {
+			t.Errorf("Available(XENDIT, %s) = true while Xendit is unreachable", method)
+		}
+	}
+	if !breakers.Available(domain.GatewayStripe, "CARD") {
+		t.Error("Available(STRIPE, CARD) = false while Xendit is unreachable")
+	}
+}
+
+func TestGatewayBreakersIgnoreDeclines(t *testing.T) {
+	breakers := NewGatewayBreakers(testBreakerConfig)
+	declined := &domain.GatewayError{Gateway: domain.GatewayStripe, Err: errors.New("card declined")}
+	failCalls(t, breakers, domain.GatewayStripe, "CARD", declined, 2*testBreakerConfig.FailureThreshold)
+
+	if !breakers.Available(domain.GatewayStripe, "CARD") {
+		t.Error("Available(STRIPE, CARD) = false after declined payments")
+	}
+}
@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"fmt"
+	"payment-service/internal/domain"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// GatewayRegistry holds the payment gateway adapters by gateway code, so the
+// use case can route payments without knowing the concrete adapters.
+type GatewayRegistry struct {
+	mu       sync.RWMutex
+	gateways map[string]domain.PaymentGateway
+}
+
+func NewGatewayRegistry() *GatewayRegistry {
+	return &GatewayRegistry{
+		gateways: make(map[string]domain.PaymentGateway),
+	}
+}
+
+// NormalizeGatewayCode returns the canonical form of a gateway code; the config
+// service is not consistent about casing.
+func NormalizeGatewayCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Register adds gateway under code, replacing any adapter already registered under it.
+func (r *GatewayRegistry) Register(code string, gateway domain.PaymentGateway) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gateways[NormalizeGatewayCode(code)] = gateway
+}
+
+// Get returns the adapter registered under code.
+func (r *GatewayRegistry) Get(code string) (domain.PaymentGateway, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	gateway, ok := r.gateways[NormalizeGatewayCode(code)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", domain.ErrUnsupportedGateway, code)
+	}
+	return gateway, nil
+}
+
+// Codes returns the codes of the registered adapters in sorted order.
+func (r *GatewayRegistry) Codes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	codes := make([]string, 0, len(r.gateways))
+	for code := range r.gateways {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Resolve returns the adapter registered under code if it supports paymentMethod.
+func (r *GatewayRegistry) Resolve(code, paymentMethod string) (domain.PaymentGateway, error) {
+	gateway, err := r.Get(code)
+	if err != nil {
+		return nil, err
+	}
+	for _, method := range gateway.SupportedMethods() {
+		if method == paymentMethod {
+			return gateway, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q for gateway %s", domain.ErrUnsupportedPaymentMethod, paymentMethod, NormalizeGatewayCode(code))
+}
@@ -0,0 +1,279 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"payment-service/internal/domain"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PaymentStatusNotifier is told about every change of a payment's status.
+type PaymentStatusNotifier interface {
+	// PaymentStatusChanged is called with the payment as it is after the change.
+	PaymentStatusChanged(ctx context.Context, payment *domain.Payment, previous domain.PaymentStatus)
+}
+
+// MerchantWebhookUseCase sends merchants a signed webhook whenever the status
+// of one of their payments changes, retrying failed deliveries.
+type MerchantWebhookUseCase interface {
+	PaymentStatusNotifier
+	// RegisterWebhook sets the agent's callback URL and issues a new signing secret.
+	RegisterWebhook(ctx context.Context, agent, callbackURL string) (*domain.WebhookSubscription, error)
+	ListDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter, page, pageSize int) ([]domain.WebhookDelivery, int, error)
+	// Redeliver schedules a delivery to be sent again right away, with a fresh
+	// set of attempts, whatever its state.
+	Redeliver(ctx context.Context, deliveryID string) (*domain.WebhookDelivery, error)
+	// DeliverDue sends the deliveries whose next attempt is due and returns how
+	// many were attempted.
+	DeliverDue(ctx context.Context) (int, error)
+}
+
+// MerchantWebhookConfig controls how merchant webhooks are retried. A delivery
+// is attempted MaxAttempts times, waiting InitialBackoff after the first
+// failure and twice as long after each further one, up to MaxBackoff.
+type MerchantWebhookConfig struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// BatchSize is how many due deliveries DeliverDue sends at most.
+	BatchSize int
+}
+
+// DefaultMerchantWebhookConfig retries for about a day before dead-lettering.
+var DefaultMerchantWebhookConfig = MerchantWebhookConfig{
+	MaxAttempts:    10,
+	InitialBackoff: 30 * time.Second,
+	MaxBackoff:     6 * time.Hour,
+	BatchSize:      50,
+}
+
+const (
+	// merchantWebhookSignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256
+	// of "<t>.<body>" keyed with the subscription secret>".
+	merchantWebhookSignatureHeader = "X-Webhook-Signature"
+	merchantWebhookIDHeader        = "X-Webhook-Id"
+	merchantWebhookEventHeader     = "X-Webhook-Event"
+	// merchantWebhookLease is how long a claimed delivery is held back from other
+	// instances; it must outlast the HTTP timeout.
+	merchantWebhookLease = 2 * time.Minute
+)
+
+type merchantWebhookUseCase struct {
+	subscriptions domain.WebhookSubscriptionRepository
+	deliveries    domain.WebhookDeliveryRepository
+	httpClient    *http.Client
+	config        MerchantWebhookConfig
+}
+
+func NewMerchantWebhookUseCase(subscriptions domain.WebhookSubscriptionRepository, deliveries domain.WebhookDeliveryRepository, httpClient *http.Client, config MerchantWebhookConfig) MerchantWebhookUseCase {
+	return &merchantWebhookUseCase{
+		subscriptions: subscriptions,
+		deliveries:    deliveries,
+		httpClient:    httpClient,
+		config:        config,
+	}
+}
+
+func (uc *merchantWebhookUseCase) RegisterWebhook(ctx context.Context, agent, callbackURL string) (*domain.WebhookSubscription, error) {
+	parsed, err := url.Parse(callbackURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: %q must be an absolute https URL", domain.ErrInvalidCallbackURL, callbackURL)
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	subscription := &domain.WebhookSubscription{
+		SubscriptionID: uuid.New().String(),
+		Agent:          agent,
+		CallbackURL:    callbackURL,
+		Secret:         secret,
+	}
+	if err := uc.subscriptions.Upsert(ctx, subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (uc *merchantWebhookUseCase) ListDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter, page, pageSize int) ([]domain.WebhookDelivery, int, error) {
+	return uc.deliveries.Find(ctx, filter, page, pageSize)
+}
+
+func (uc *merchantWebhookUseCase) Redeliver(ctx context.Context, deliveryID string) (*domain.WebhookDelivery, error) {
+	delivery, err := uc.deliveries.FindByID(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Status = domain.WebhookDeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	if err := uc.deliveries.Update(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// merchantWebhookPayload is the JSON body of a payment.status_changed webhook.
+type merchantWebhookPayload struct {
+	Event               string    `json:"event"`
+	DeliveryID          string    `json:"delivery_id"`
+	PaymentID           string    `json:"payment_id"`
+	InvoiceNumber       string    `json:"invoice_number"`
+	Agent               string    `json:"agent"`
+	Status              string    `json:"status"`
+	PreviousStatus      string    `json:"previous_status"`
+	Currency            string    `json:"currency"`
+	AmountMinor         int64     `json:"amount_minor"`
+	RefundedAmountMinor int64     `json:"refunded_amount_minor"`
+	OccurredAt          time.Time `json:"occurred_at"`
+}
+
+// PaymentStatusChanged queues a webhook to the payment's agent, if the agent
+// has registered one. Failures are logged; they must not fail the status change.
+func (uc *merchantWebhookUseCase) PaymentStatusChanged(ctx context.Context, payment *domain.Payment, previous domain.PaymentStatus) {
+	subscription, err := uc.subscriptions.FindByAgent(ctx, payment.Agent)
+	if errors.Is(err, domain.ErrWebhookSubscriptionNotFound) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding webhook subscription of agent %s: %v", payment.Agent, err)
+		return
+	}
+
+	now := time.Now()
+	delivery := &domain.WebhookDelivery{
+		DeliveryID:     uuid.New().String(),
+		SubscriptionID: subscription.SubscriptionID,
+		Agent:          payment.Agent,
+		PaymentID:      payment.PaymentID,
+		EventType:      domain.EventPaymentStatusChanged,
+		Status:         domain.WebhookDeliveryStatusPending,
+		NextAttemptAt:  now,
+	}
+	// Marshalling a struct of plain fields cannot fail.
+	payload, _ := json.Marshal(merchantWebhookPayload{
+		Event:               delivery.EventType,
+		DeliveryID:          delivery.DeliveryID,
+		PaymentID:           payment.PaymentID,
+		InvoiceNumber:       payment.InvoiceNumber,
+		Agent:               payment.Agent,
+		Status:              string(payment.Status),
+		PreviousStatus:      string(previous),
+		Currency:            payment.Amount.Currency,
+		AmountMinor:         payment.Amount.MinorUnits,
+		RefundedAmountMinor: payment.RefundedAmount.MinorUnits,
+		OccurredAt:          now,
+	})
+	delivery.Payload = string(payload)
+
+	if err := uc.deliveries.Save(ctx, delivery); err != nil {
+		log.Printf("Error queueing webhook for payment %s: %v", payment.PaymentID, err)
+	}
+}
+
+func (uc *merchantWebhookUseCase) DeliverDue(ctx context.Context) (int, error) {
+	due, err := uc.deliveries.ClaimDue(ctx, time.Now(), merchantWebhookLease, uc.config.BatchSize)
+	for i := range due {
+		uc.deliver(ctx, &due[i])
+	}
+	return len(due), err
+}
+
+// deliver makes one attempt at sending delivery and records its outcome.
+func (uc *merchantWebhookUseCase) deliver(ctx context.Context, delivery *domain.WebhookDelivery) {
+	delivery.Attempts++
+	code, err := uc.send(ctx, delivery)
+	delivery.LastResponseCode = code
+
+	switch {
+	case err == nil:
+		delivery.Status = domain.WebhookDeliveryStatusSucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = time.Now()
+	case delivery.Attempts >= uc.config.MaxAttempts || errors.Is(err, domain.ErrWebhookSubscriptionNotFound):
+		delivery.Status = domain.WebhookDeliveryStatusDeadLetter
+		delivery.LastError = err.Error()
+		log.Printf("Webhook delivery %s to agent %s dead-lettered after %d attempts: %v", delivery.DeliveryID, delivery.Agent, delivery.Attempts, err)
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = time.Now().Add(uc.backoff(delivery.Attempts))
+	}
+
+	if err := uc.deliveries.Update(ctx, delivery); err != nil {
+		log.Printf("Error recording webhook delivery %s: %v", delivery.DeliveryID, err)
+	}
+}
+
+// send posts delivery to the agent's current callback URL, signed with the
+// current secret, and returns the response status code.
+func (uc *merchantWebhookUseCase) send(ctx context.Context, delivery *domain.WebhookDelivery) (int, error) {
+	subscription, err := uc.subscriptions.FindByID(ctx, delivery.SubscriptionID)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.CallbackURL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(merchantWebhookIDHeader, delivery.DeliveryID)
+	req.Header.Set(merchantWebhookEventHeader, delivery.EventType)
+	req.Header.Set(merchantWebhookSignatureHeader, "t="+timestamp+",v1="+merchantWebhookSignature(subscription.Secret, timestamp, delivery.Payload))
+
+	resp, err := uc.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("callback responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns how long to wait after the given number of failed attempts.
+func (uc *merchantWebhookUseCase) backoff(attempts int) time.Duration {
+	wait := uc.config.InitialBackoff
+	for i := 1; i < attempts && wait < uc.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > uc.config.MaxBackoff {
+		wait = uc.config.MaxBackoff
+	}
+	return wait
+}
+
+// merchantWebhookSignature signs "<timestamp>.<payload>", binding the signature
+// to the time of sending so a captured request cannot be replayed later.
+func merchantWebhookSignature(secret, timestamp, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
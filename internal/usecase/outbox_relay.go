@@ -0,0 +1,61 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"payment-service/internal/domain"
+	"time"
+)
+
+const (
+	// outboxLease is how long a claimed event is held back from other relays.
+	outboxLease = time.Minute
+	// outboxMaxBackoff caps the wait between attempts at publishing an event.
+	outboxMaxBackoff = 5 * time.Minute
+)
+
+// OutboxRelay publishes the events stored in the outbox. Events are published
+// at least once, in the order they were written as long as publishing succeeds.
+type OutboxRelay struct {
+	outbox    domain.OutboxRepository
+	publisher domain.EventPublisher
+	batchSize int
+}
+
+func NewOutboxRelay(outbox domain.OutboxRepository, publisher domain.EventPublisher, batchSize int) *OutboxRelay {
+	return &OutboxRelay{outbox: outbox, publisher: publisher, batchSize: batchSize}
+}
+
+// RelayPending publishes the events that are due and returns how many were published.
+func (r *OutboxRelay) RelayPending(ctx context.Context) (int, error) {
+	events, err := r.outbox.ClaimPending(ctx, time.Now(), outboxLease, r.batchSize)
+	published := 0
+	for _, event := range events {
+		if publishErr := r.publisher.Publish(ctx, event); publishErr != nil {
+			log.Printf("Error publishing %s event %s: %v", event.Type, event.EventID, publishErr)
+			if markErr := r.outbox.MarkFailed(ctx, event.EventID, publishErr, time.Now().Add(outboxBackoff(event.Attempts+1))); markErr != nil {
+				log.Printf("Error recording failed publish of event %s: %v", event.EventID, markErr)
+			}
+			continue
+		}
+		if markErr := r.outbox.MarkPublished(ctx, event.EventID); markErr != nil {
+			// The event will be published again once its lease runs out.
+			log.Printf("Error marking event %s as published: %v", event.EventID, markErr)
+			continue
+		}
+		published++
+	}
+	return published, err
+}
+
+// outboxBackoff returns how long to wait after the given number of failed attempts.
+func outboxBackoff(attempts int) time.Duration {
+	wait := time.Second
+	for i := 1; i < attempts && wait < outboxMaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > outboxMaxBackoff {
+		wait = outboxMaxBackoff
+	}
+	return wait
+}
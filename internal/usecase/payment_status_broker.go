@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"context"
+	"payment-service/internal/domain"
+	"sync"
+)
+
+// PaymentStatusBroker fans payment status changes out to the watchers of each
+// payment within this process.
+type PaymentStatusBroker struct {
+	mu       sync.Mutex
+	watchers map[string]map[chan domain.Payment]struct{}
+}
+
+func NewPaymentStatusBroker() *PaymentStatusBroker {
+	return &PaymentStatusBroker{
+		watchers: make(map[string]map[chan domain.Payment]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving the payment after each of its status
+// changes, and a function that ends the subscription. A slow watcher only
+// misses intermediate changes; the latest one is always kept for it.
+func (b *PaymentStatusBroker) Subscribe(paymentID string) (<-chan domain.Payment, func()) {
+	updates := make(chan domain.Payment, 1)
+
+	b.mu.Lock()
+	if b.watchers[paymentID] == nil {
+		b.watchers[paymentID] = make(map[chan domain.Payment]struct{})
+	}
+	b.watchers[paymentID][updates] = struct{}{}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.watchers[paymentID], updates)
+		if len(b.watchers[paymentID]) == 0 {
+			delete(b.watchers, paymentID)
+		}
+	}
+	return updates, unsubscribe
+}
+
+func (b *PaymentStatusBroker) PaymentStatusChanged(ctx context.Context, payment *domain.Payment, previous domain.PaymentStatus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for updates := range b.watchers[payment.PaymentID] {
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- *payment
+	}
+}
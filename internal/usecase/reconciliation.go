@@ -0,0 +1,233 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"payment-service/internal/domain"
+	"strings"
+	"time"
+)
+
+// ReconciliationUseCase compares our payments with the transactions the
+// gateways report, to find missed webhooks and payments never saved.
+type ReconciliationUseCase interface {
+	// Reconcile reports the discrepancies for gateway between from and to. With
+	// autoHeal, payments still pending, or expired, that the gateway settled, and
+	// payments left initiated that the gateway has, are moved to the gateway's status.
+	Reconcile(ctx context.Context, gateway string, from, to time.Time, autoHeal bool) (*domain.ReconciliationReport, error)
+	// ReconcileAll reconciles every registered gateway that can list its transactions.
+	ReconcileAll(ctx context.Context, from, to time.Time, autoHeal bool) ([]domain.ReconciliationReport, error)
+}
+
+type reconciliationUseCase struct {
+	gateways    *GatewayRegistry
+	paymentRepo domain.PaymentRepository
+	// payments applies healed statuses, so that they are published and
+	// notified like statuses reported by webhooks.
+	payments PaymentUseCase
+}
+
+func NewReconciliationUseCase(gateways *GatewayRegistry, paymentRepo domain.PaymentRepository, payments PaymentUseCase) ReconciliationUseCase {
+	return &reconciliationUseCase{
+		gateways:    gateways,
+		paymentRepo: paymentRepo,
+		payments:    payments,
+	}
+}
+
+func (uc *reconciliationUseCase) ReconcileAll(ctx context.Context, from, to time.Time, autoHeal bool) ([]domain.ReconciliationReport, error) {
+	var reports []domain.ReconciliationReport
+	for _, code := range uc.gateways.Codes() {
+		report, err := uc.Reconcile(ctx, code, from, to, autoHeal)
+		if errors.Is(err, domain.ErrReconciliationNotSupported) {
+			continue
+		}
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
+
+func (uc *reconciliationUseCase) Reconcile(ctx context.Context, gateway string, from, to time.Time, autoHeal bool) (*domain.ReconciliationReport, error) {
+	code := NormalizeGatewayCode(gateway)
+	adapter, err := uc.gateways.Get(code)
+	if err != nil {
+		return nil, err
+	}
+	lister, ok := adapter.(domain.TransactionLister)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrReconciliationNotSupported, code)
+	}
+
+	transactions, err := lister.ListTransactions(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("list %s transactions: %w", code, err)
+	}
+	payments, err := uc.paymentRepo.FindByGatewayCreatedBetween(ctx, code, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	local := make(map[string]*domain.Payment, len(payments))
+	for i := range payments {
+		local[reconciliationKey(payments[i].ExternalID, payments[i].GatewayReference)] = &payments[i]
+	}
+
+	report := &domain.ReconciliationReport{Gateway: code, From: from, To: to}
+	for _, transaction := range transactions {
+		key := reconciliationKey(transaction.ExternalID, transaction.GatewayReference)
+		payment, ok := local[key]
+		if ok {
+			delete(local, key)
+		} else {
+			// Our clock and the gateway's differ, so the payment may have been
+			// created just outside the period, and a payment may be known by a
+			// different ID on each side.
+			payment, err = uc.findTransactionPayment(ctx, transaction)
+			if errors.Is(err, domain.ErrPaymentNotFound) {
+				report.Discrepancies = append(report.Discrepancies, domain.Discrepancy{
+					Type:         domain.DiscrepancyMissingLocally,
+					Gateway:      code,
+					ExternalID:   transaction.ExternalID,
+					RemoteAmount: transaction.Amount,
+					RemoteStatus: transaction.Status,
+				})
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			delete(local, reconciliationKey(payment.ExternalID, payment.GatewayReference))
+		}
+
+		discrepancies := compareTransaction(code, payment, transaction)
+		if len(discrepancies) == 0 {
+			report.Matched++
+			continue
+		}
+		for _, discrepancy := range discrepancies {
+			if autoHeal && discrepancy.Type == domain.DiscrepancyStatusMismatch && healable(payment.Status, transaction.Status) {
+				uc.heal(ctx, &discrepancy, transaction)
+			}
+			report.Discrepancies = append(report.Discrepancies, discrepancy)
+		}
+	}
+
+	// Gateways may not list payments nobody paid, but they know every payment
+	// that took money.
+	for _, payment := range local {
+		if reconciledStatus(payment.Status) != domain.PaymentStatusPaid {
+			continue
+		}
+		report.Discrepancies = append(report.Discrepancies, domain.Discrepancy{
+			Type:        domain.DiscrepancyMissingRemotely,
+			Gateway:     code,
+			ExternalID:  payment.ExternalID,
+			PaymentID:   payment.PaymentID,
+			LocalAmount: payment.Amount,
+			LocalStatus: payment.Status,
+		})
+	}
+
+	report.GeneratedAt = time.Now()
+	log.Printf("Reconciled %s from %s to %s: %d matched, %d discrepancies", code, from.Format(time.RFC3339), to.Format(time.RFC3339), report.Matched, len(report.Discrepancies))
+	return report, nil
+}
+
+// findTransactionPayment looks up the payment of a transaction by external ID,
+// or by the gateway's ID if the gateway did not report one or no payment has it.
+func (uc *reconciliationUseCase) findTransactionPayment(ctx context.Context, transaction domain.GatewayTransaction) (*domain.Payment, error) {
+	if transaction.ExternalID != "" {
+		payment, err := uc.paymentRepo.FindByExternalID(ctx, transaction.ExternalID)
+		if !errors.Is(err, domain.ErrPaymentNotFound) || transaction.GatewayReference == "" {
+			return payment, err
+		}
+	}
+	if transaction.GatewayReference == "" {
+		return nil, domain.ErrPaymentNotFound
+	}
+	return uc.paymentRepo.FindByGatewayReference(ctx, transaction.GatewayReference)
+}
+
+// heal moves the payment of discrepancy to the status the gateway reported, as
+// a webhook for it would have.
+func (uc *reconciliationUseCase) heal(ctx context.Context, discrepancy *domain.Discrepancy, transaction domain.GatewayTransaction) {
+	_, err := uc.payments.ApplyPaymentNotification(ctx, domain.PaymentNotification{
+		Gateway:          transaction.Gateway,
+		ExternalID:       transaction.ExternalID,
+		GatewayReference: transaction.GatewayReference,
+		Status:           transaction.Status,
+	})
+	if err != nil {
+		log.Printf("Error healing payment %s to %s: %v", discrepancy.PaymentID, transaction.Status, err)
+		discrepancy.HealError = err.Error()
+		return
+	}
+	log.Printf("Healed payment %s from %s to %s", discrepancy.PaymentID, discrepancy.LocalStatus, transaction.Status)
+	discrepancy.Healed = true
+}
+
+// compareTransaction returns the differences between a payment and its gateway transaction.
+func compareTransaction(gateway string, payment *domain.Payment, transaction domain.GatewayTransaction) []domain.Discrepancy {
+	discrepancy := domain.Discrepancy{
+		Gateway:      gateway,
+		ExternalID:   payment.ExternalID,
+		PaymentID:    payment.PaymentID,
+		LocalAmount:  payment.Amount,
+		RemoteAmount: transaction.Amount,
+		LocalStatus:  payment.Status,
+		RemoteStatus: transaction.Status,
+	}
+
+	var discrepancies []domain.Discrepancy
+	if payment.Amount.MinorUnits != transaction.Amount.MinorUnits ||
+		!strings.EqualFold(payment.Amount.Currency, transaction.Amount.Currency) {
+		discrepancy.Type = domain.DiscrepancyAmountMismatch
+		discrepancies = append(discrepancies, discrepancy)
+	}
+	if reconciledStatus(payment.Status) != transaction.Status {
+		discrepancy.Type = domain.DiscrepancyStatusMismatch
+		discrepancies = append(discrepancies, discrepancy)
+	}
+	return discrepancies
+}
+
+// healable reports whether a payment in status local may safely be moved to the
+// status a gateway reported: only outcomes of a payment that is still open, or
+// that we expired and the gateway took anyway, are applied. A payment left
+// initiated that the gateway lists was created there, whatever its status.
+func healable(local, remote domain.PaymentStatus) bool {
+	if local == domain.PaymentStatusInitiated {
+		return true
+	}
+	if !remote.IsSettlement() {
+		return false
+	}
+	return local == domain.PaymentStatusPending ||
+		(local == domain.PaymentStatusExpired && remote == domain.PaymentStatusPaid)
+}
+
+// reconciledStatus returns the status a gateway reports for a payment in
+// status. Gateways list the payment, not what happened to it afterwards, so
+// refunded and disputed payments are paid ones.
+func reconciledStatus(status domain.PaymentStatus) domain.PaymentStatus {
+	switch status {
+	case domain.PaymentStatusPartiallyRefunded, domain.PaymentStatusRefunded, domain.PaymentStatusDisputed:
+		return domain.PaymentStatusPaid
+	default:
+		return status
+	}
+}
+
+// reconciliationKey identifies a payment on both sides: by external ID, or by
+// the gateway's ID for payments created before external IDs were stored.
+func reconciliationKey(externalID, gatewayReference string) string {
+	if externalID != "" {
+		return "external:" + externalID
+	}
+	return "reference:" + gatewayReference
+}
@@ -0,0 +1,410 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"payment-service/internal/domain"
+	"testing"
+	"time"
+)
+
+// fakeGateway is a gateway adapter that cannot list its transactions.
+type fakeGateway struct{}
+
+func (g *fakeGateway) SupportedMethods() []string {
+	return nil
+}
+
+func (g *fakeGateway) ProcessPayment(ctx context.Context, payment *domain.Payment) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (g *fakeGateway) RefundPayment(ctx context.Context, payment *domain.Payment, amount domain.Money) (*domain.GatewayRefund, error) {
+	return nil, errors.New("not implemented")
+}
+
+// fakeListingGateway is a gateway adapter reporting a fixed list of transactions.
+type fakeListingGateway struct {
+	fakeGateway
+	transactions []domain.GatewayTransaction
+}
+
+func (g *fakeListingGateway) ListTransactions(ctx context.Context, from, to time.Time) ([]domain.GatewayTransaction, error) {
+	var transactions []domain.GatewayTransaction
+	for _, transaction := range g.transactions {
+		if !transaction.CreatedAt.Before(from) && transaction.CreatedAt.Before(to) {
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions, nil
+}
+
+// fakePaymentRepository holds payments in memory. Only the lookups used by
+// reconciliation are implemented.
+type fakePaymentRepository struct {
+	domain.PaymentRepository
+	payments []domain.Payment
+}
+
+func (r *fakePaymentRepository) FindByGatewayCreatedBetween(ctx context.Context, gateway string, from, to time.Time) ([]domain.Payment, error) {
+	var payments []domain.Payment
+	for _, payment := range r.payments {
+		if payment.Gateway == gateway && !payment.CreatedAt.Before(from) && payment.CreatedAt.Before(to) {
+			payments = append(payments, payment)
+		}
+	}
+	return payments, nil
+}
+
+func (r *fakePaymentRepository) FindByExternalID(ctx context.Context, externalID string) (*domain.Payment, error) {
+	for i := range r.payments {
+		if r.payments[i].ExternalID == externalID {
+			return &r.payments[i], nil
+		}
+	}
+	return nil, domain.ErrPaymentNotFound
+}
+
+func (r *fakePaymentRepository) FindByGatewayReference(ctx context.Context, gatewayReference string) (*domain.Payment, error) {
+	for i := range r.payments {
+		if r.payments[i].GatewayReference == gatewayReference {
+			return &r.payments[i], nil
+		}
+	}
+	return nil, domain.ErrPaymentNotFound
+}
+
+// fakePaymentUseCase records the notifications reconciliation heals payments with.
+type fakePaymentUseCase struct {
+	PaymentUseCase
+	notifications []domain.PaymentNotification
+	err           error
+}
+
+func (uc *fakePaymentUseCase) ApplyPaymentNotification(ctx context.Context, notification domain.PaymentNotification) (string, error) {
+	uc.notifications = append(uc.notifications, notification)
+	if uc.err != nil {
+		return "failed", uc.err
+	}
+	return "Success", nil
+}
+
+var (
+	reconcileFrom = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	reconcileTo   = reconcileFrom.Add(time.Hour)
+	inPeriod      = reconcileFrom.Add(30 * time.Minute)
+)
+
+func idr(minorUnits int64) domain.Money {
+	return domain.Money{MinorUnits: minorUnits, Currency: "IDR"}
+}
+
+func newReconciliation(transactions []domain.GatewayTransaction, payments []domain.Payment) (ReconciliationUseCase, *fakePaymentUseCase) {
+	gateways := NewGatewayRegistry()
+	gateways.Register(domain.GatewayXendit, &fakeListingGateway{transactions: transactions})
+	healer := &fakePaymentUseCase{}
+	return NewReconciliationUseCase(gateways, &fakePaymentRepository{payments: payments}, healer), healer
+}
+
+func TestReconcileDiscrepancies(t *testing.T) {
+	tests := []struct {
+		name         string
+		transactions []domain.GatewayTransaction
+		payments     []domain.Payment
+		matched      int
+		want         []domain.DiscrepancyType
+	}{
+		{
+			name: "matched",
+			transactions: []domain.GatewayTransaction{
+				{Gateway: domain.GatewayXendit, ExternalID: "ext-1", Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			payments: []domain.Payment{
+				{PaymentID: "pay-1", ExternalID: "ext-1", Gateway: domain.GatewayXendit, Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			matched: 1,
+		},
+		{
+			name: "refunded payment matches a paid transaction",
+			transactions: []domain.GatewayTransaction{
+				{Gateway: domain.GatewayXendit, ExternalID: "ext-1", Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			payments: []domain.Payment{
+				{PaymentID: "pay-1", ExternalID: "ext-1", Gateway: domain.GatewayXendit, Amount: idr(10000), Status: domain.PaymentStatusRefunded, CreatedAt: inPeriod},
+			},
+			matched: 1,
+		},
+		{
+			name: "missing locally",
+			transactions: []domain.GatewayTransaction{
+				{Gateway: domain.GatewayXendit, ExternalID: "ext-unknown", Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			want: []domain.DiscrepancyType{domain.DiscrepancyMissingLocally},
+		},
+		{
+			name: "missing remotely",
+			payments: []domain.Payment{
+				{PaymentID: "pay-1", ExternalID: "ext-1", Gateway: domain.GatewayXendit, Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			want: []domain.DiscrepancyType{domain.DiscrepancyMissingRemotely},
+		},
+		{
+			name: "unpaid payment the gateway does not list",
+			payments: []domain.Payment{
+				{PaymentID: "pay-1", ExternalID: "ext-1", Gateway: domain.GatewayXendit, Amount: idr(10000), Status: domain.PaymentStatusPending, CreatedAt: inPeriod},
+			},
+		},
+		{
+			name: "amount mismatch",
+			transactions: []domain.GatewayTransaction{
+				{Gateway: domain.GatewayXendit, ExternalID: "ext-1", Amount: idr(9000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			payments: []domain.Payment{
+				{PaymentID: "pay-1", ExternalID: "ext-1", Gateway: domain.GatewayXendit, Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			want: []domain.DiscrepancyType{domain.DiscrepancyAmountMismatch},
+		},
+		{
+			name: "currency mismatch",
+			transactions: []domain.GatewayTransaction{
+				{Gateway: domain.GatewayXendit, ExternalID: "ext-1", Amount: domain.Money{MinorUnits: 10000, Currency: "USD"}, Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			payments: []domain.Payment{
+				{PaymentID: "pay-1", ExternalID: "ext-1", Gateway: domain.GatewayXendit, Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			want: []domain.DiscrepancyType{domain.DiscrepancyAmountMismatch},
+		},
+		{
+			name: "status mismatch",
+			transactions: []domain.GatewayTransaction{
+				{Gateway: domain.GatewayXendit, ExternalID: "ext-1", Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			payments: []domain.Payment{
+				{PaymentID: "pay-1", ExternalID: "ext-1", Gateway: domain.GatewayXendit, Amount: idr(10000), Status: domain.PaymentStatusPending, CreatedAt: inPeriod},
+			},
+			want: []domain.DiscrepancyType{domain.DiscrepancyStatusMismatch},
+		},
+		{
+			name: "amount and status mismatch",
+			transactions: []domain.GatewayTransaction{
+				{Gateway: domain.GatewayXendit, ExternalID: "ext-1", Amount: idr(9000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			payments: []domain.Payment{
+				{PaymentID: "pay-1", ExternalID: "ext-1", Gateway: domain.GatewayXendit, Amount: idr(10000), Status: domain.PaymentStatusPending, CreatedAt: inPeriod},
+			},
+			want: []domain.DiscrepancyType{domain.DiscrepancyAmountMismatch, domain.DiscrepancyStatusMismatch},
+		},
+		{
+			name: "matched by gateway reference",
+			transactions: []domain.GatewayTransaction{
+				{Gateway: domain.GatewayXendit, GatewayReference: "qr-1", Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			payments: []domain.Payment{
+				{PaymentID: "pay-1", GatewayReference: "qr-1", Gateway: domain.GatewayXendit, Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			matched: 1,
+		},
+		{
+			name: "matched by gateway reference to a payment with an external ID",
+			transactions: []domain.GatewayTransaction{
+				{Gateway: domain.GatewayXendit, GatewayReference: "qr-1", Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			payments: []domain.Payment{
+				{PaymentID: "pay-1", ExternalID: "ext-1", GatewayReference: "qr-1", Gateway: domain.GatewayXendit, Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			matched: 1,
+		},
+		{
+			name: "matched by external ID to a payment known by gateway reference",
+			transactions: []domain.GatewayTransaction{
+				{Gateway: domain.GatewayXendit, ExternalID: "ext-1", GatewayReference: "inv-1", Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			payments: []domain.Payment{
+				{PaymentID: "pay-1", GatewayReference: "inv-1", Gateway: domain.GatewayXendit, Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+			},
+			matched: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reconciliation, _ := newReconciliation(tt.transactions, tt.payments)
+			report, err := reconciliation.Reconcile(context.Background(), "xendit", reconcileFrom, reconcileTo, false)
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if report.Gateway != domain.GatewayXendit {
+				t.Errorf("Gateway = %q, want %q", report.Gateway, domain.GatewayXendit)
+			}
+			if report.Matched != tt.matched {
+				t.Errorf("Matched = %d, want %d", report.Matched, tt.matched)
+			}
+			if len(report.Discrepancies) != len(tt.want) {
+				t.Fatalf("Discrepancies = %+v, want types %v", report.Discrepancies, tt.want)
+			}
+			for i, discrepancy := range report.Discrepancies {
+				if discrepancy.Type != tt.want[i] {
+					t.Errorf("Discrepancies[%d].Type = %q, want %q", i, discrepancy.Type, tt.want[i])
+				}
+				if discrepancy.Healed {
+					t.Errorf("Discrepancies[%d] healed without auto-heal", i)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileFindsPaymentsCreatedOutsideThePeriod(t *testing.T) {
+	// Our clock ran ahead of the gateway's, so the payments were stored after the period ended.
+	late := reconcileTo.Add(2 * time.Second)
+	transactions := []domain.GatewayTransaction{
+		{Gateway: domain.GatewayXendit, ExternalID: "ext-1", Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: reconcileTo.Add(-time.Second)},
+		{Gateway: domain.GatewayXendit, GatewayReference: "qr-2", Amount: idr(20000), Status: domain.PaymentStatusPaid, CreatedAt: reconcileTo.Add(-time.Second)},
+	}
+	payments := []domain.Payment{
+		{PaymentID: "pay-1", ExternalID: "ext-1", Gateway: domain.GatewayXendit, Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: late},
+		{PaymentID: "pay-2", GatewayReference: "qr-2", Gateway: domain.GatewayXendit, Amount: idr(20000), Status: domain.PaymentStatusPaid, CreatedAt: late},
+	}
+
+	reconciliation, _ := newReconciliation(transactions, payments)
+	report, err := reconciliation.Reconcile(context.Background(), domain.GatewayXendit, reconcileFrom, reconcileTo, false)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if report.Matched != 2 || len(report.Discrepancies) != 0 {
+		t.Errorf("Matched = %d, Discrepancies = %+v, want 2 matched and none", report.Matched, report.Discrepancies)
+	}
+}
+
+func TestReconcileAutoHeal(t *testing.T) {
+	tests := []struct {
+		name   string
+		local  domain.PaymentStatus
+		remote domain.PaymentStatus
+		err    error
+		healed bool
+	}{
+		{name: "pending payment paid", local: domain.PaymentStatusPending, remote: domain.PaymentStatusPaid, healed: true},
+		{name: "pending payment failed", local: domain.PaymentStatusPending, remote: domain.PaymentStatusFailed, healed: true},
+		{name: "expired payment paid", local: domain.PaymentStatusExpired, remote: domain.PaymentStatusPaid, healed: true},
+		{name: "initiated payment pending", local: domain.PaymentStatusInitiated, remote: domain.PaymentStatusPending, healed: true},
+		{name: "initiated payment paid", local: domain.PaymentStatusInitiated, remote: domain.PaymentStatusPaid, healed: true},
+		{name: "paid payment failed", local: domain.PaymentStatusPaid, remote: domain.PaymentStatusFailed},
+		{name: "failed payment paid", local: domain.PaymentStatusFailed, remote: domain.PaymentStatusPaid},
+		{name: "expired payment failed", local: domain.PaymentStatusExpired, remote: domain.PaymentStatusFailed},
+		{name: "pending payment cancelled", local: domain.PaymentStatusPending, remote: domain.PaymentStatusCancelled},
+		{name: "heal rejected", local: domain.PaymentStatusPending, remote: domain.PaymentStatusPaid, err: domain.ErrInvalidStatusTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transactions := []domain.GatewayTransaction{
+				{Gateway: domain.GatewayXendit, ExternalID: "ext-1", GatewayReference: "inv-1", Amount: idr(10000), Status: tt.remote, CreatedAt: inPeriod},
+			}
+			payments := []domain.Payment{
+				{PaymentID: "pay-1", ExternalID: "ext-1", Gateway: domain.GatewayXendit, Amount: idr(10000), Status: tt.local, CreatedAt: inPeriod},
+			}
+			reconciliation, healer := newReconciliation(transactions, payments)
+			healer.err = tt.err
+
+			report, err := reconciliation.Reconcile(context.Background(), domain.GatewayXendit, reconcileFrom, reconcileTo, true)
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if len(report.Discrepancies) != 1 || report.Discrepancies[0].Type != domain.DiscrepancyStatusMismatch {
+				t.Fatalf("Discrepancies = %+v, want one status mismatch", report.Discrepancies)
+			}
+			discrepancy := report.Discrepancies[0]
+
+			attempted := tt.healed || tt.err != nil
+			if attempted != (len(healer.notifications) == 1) {
+				t.Fatalf("notifications = %+v, want heal attempted %t", healer.notifications, attempted)
+			}
+			if attempted {
+				notification := healer.notifications[0]
+				if notification.ExternalID != "ext-1" || notification.GatewayReference != "inv-1" || notification.Status != tt.remote {
+					t.Errorf("notification = %+v, want ext-1/inv-1 in %q", notification, tt.remote)
+				}
+			}
+			if discrepancy.Healed != tt.healed {
+				t.Errorf("Healed = %t, want %t", discrepancy.Healed, tt.healed)
+			}
+			if (discrepancy.HealError != "") != (tt.err != nil) {
+				t.Errorf("HealError = %q, want error %v", discrepancy.HealError, tt.err)
+			}
+		})
+	}
+}
+
+func TestReconcileAutoHealLeavesOtherDiscrepancies(t *testing.T) {
+	transactions := []domain.GatewayTransaction{
+		{Gateway: domain.GatewayXendit, ExternalID: "ext-1", Amount: idr(9000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+		{Gateway: domain.GatewayXendit, ExternalID: "ext-unknown", Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+	}
+	payments := []domain.Payment{
+		{PaymentID: "pay-1", ExternalID: "ext-1", Gateway: domain.GatewayXendit, Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+		{PaymentID: "pay-2", ExternalID: "ext-2", Gateway: domain.GatewayXendit, Amount: idr(10000), Status: domain.PaymentStatusPaid, CreatedAt: inPeriod},
+	}
+
+	reconciliation, healer := newReconciliation(transactions, payments)
+	report, err := reconciliation.Reconcile(context.Background(), domain.GatewayXendit, reconcileFrom, reconcileTo, true)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(report.Discrepancies) != 3 {
+		t.Fatalf("Discrepancies = %+v, want 3", report.Discrepancies)
+	}
+	if len(healer.notifications) != 0 {
+		t.Errorf("notifications = %+v, want none", healer.notifications)
+	}
+}
+
+func TestReconcileUnsupportedGateway(t *testing.T) {
+	gateways := NewGatewayRegistry()
+	gateways.Register(domain.GatewayDoku, &fakeGateway{})
+	gateways.Register(domain.GatewayXendit, &fakeListingGateway{})
+	reconciliation := NewReconciliationUseCase(gateways, &fakePaymentRepository{}, &fakePaymentUseCase{})
+
+	_, err := reconciliation.Reconcile(context.Background(), domain.GatewayDoku, reconcileFrom, reconcileTo, false)
+	if !errors.Is(err, domain.ErrReconciliationNotSupported) {
+		t.Errorf("Reconcile() error = %v, want %v", err, domain.ErrReconciliationNotSupported)
+	}
+	_, err = reconciliation.Reconcile(context.Background(), domain.GatewayStripe, reconcileFrom, reconcileTo, false)
+	if !errors.Is(err, domain.ErrUnsupportedGateway) {
+		t.Errorf("Reconcile() error = %v, want %v", err, domain.ErrUnsupportedGateway)
+	}
+
+	reports, err := reconciliation.ReconcileAll(context.Background(), reconcileFrom, reconcileTo, false)
+	if err != nil {
+		t.Fatalf("ReconcileAll() error = %v", err)
+	}
+	if len(reports) != 1 || reports[0].Gateway != domain.GatewayXendit {
+		t.Errorf("ReconcileAll() = %+v, want only the Xendit report", reports)
+	}
+}
+
+func TestHealable(t *testing.T) {
+	tests := []struct {
+		local, remote domain.PaymentStatus
+		want          bool
+	}{
+		{domain.PaymentStatusPending, domain.PaymentStatusPaid, true},
+		{domain.PaymentStatusPending, domain.PaymentStatusFailed, true},
+		{domain.PaymentStatusPending, domain.PaymentStatusExpired, true},
+		{domain.PaymentStatusPending, domain.PaymentStatusCancelled, false},
+		{domain.PaymentStatusExpired, domain.PaymentStatusPaid, true},
+		{domain.PaymentStatusExpired, domain.PaymentStatusFailed, false},
+		{domain.PaymentStatusInitiated, domain.PaymentStatusPending, true},
+		{domain.PaymentStatusInitiated, domain.PaymentStatusPaid, true},
+		{domain.PaymentStatusPaid, domain.PaymentStatusFailed, false},
+		{domain.PaymentStatusFailed, domain.PaymentStatusPaid, false},
+		{domain.PaymentStatusCancelled, domain.PaymentStatusPaid, false},
+		{domain.PaymentStatusRefunded, domain.PaymentStatusFailed, false},
+		{domain.PaymentStatusDisputed, domain.PaymentStatusPaid, false},
+	}
+	for _, tt := range tests {
+		if got := healable(tt.local, tt.remote); got != tt.want {
+			t.Errorf("healable(%q, %q) = %t, want %t", tt.local, tt.remote, got, tt.want)
+		}
+	}
+}
@@ -3,146 +3,382 @@ package usecase
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
 	"os"
 	"payment-service/internal/domain"
 	"payment-service/internal/infrastructure/paymentgateway"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type PaymentUseCase interface {
 	ProcessPayment(ctx context.Context, payment *domain.Payment) (*domain.Payment, error)
-	RefundPayment(ctx context.Context, paymentID string, amount float64) (string, error)
+	RefundPayment(ctx context.Context, paymentID string, amount domain.Money, reason string) (*domain.Refund, domain.PaymentStatus, error)
+	ListRefunds(ctx context.Context, paymentID string) ([]domain.Refund, error)
 	GetPayment(ctx context.Context, paymentID string) (*domain.Payment, error)
 	ListPayments(ctx context.Context, userID string, page, pageSize int) ([]domain.Payment, int, error)
-	QrWebhook(ctx context.Context, requestBody domain.XenditWebhookRequestPaymentData) (string, error)
+	ApplyPaymentNotification(ctx context.Context, notification domain.PaymentNotification) (string, error)
+	ApplyRefundNotification(ctx context.Context, notification domain.RefundNotification) (string, error)
+	// CancelPayment cancels a pending payment at its gateway and marks it cancelled.
+	CancelPayment(ctx context.Context, paymentID, reason string) (*domain.Payment, error)
+	// ExpireOverduePayments marks pending payments past their expiry as expired,
+	// deactivating them at the gateway where possible, and returns how many it expired.
+	ExpireOverduePayments(ctx context.Context, limit int) (int, error)
+	// RecoverInitiatedPayments resolves up to limit payments left initiated for
+	// longer than olderThan by asking their gateway whether they were created,
+	// and returns how many it resolved.
+	RecoverInitiatedPayments(ctx context.Context, olderThan time.Duration, limit int) (int, error)
+	// WatchPaymentStatus calls send with the payment now and after each status
+	// change, until the payment is settled, send fails or ctx is done.
+	WatchPaymentStatus(ctx context.Context, paymentID string, send func(*domain.Payment) error) error
 }
 
 type paymentUseCase struct {
-	stripeClient        *paymentgateway.StripeClient
-	xenditClient        *paymentgateway.XenditClient
-	dokuClient          *paymentgateway.DokuClient
+	gateways            *GatewayRegistry
 	paymentRepo         domain.PaymentRepository
+	refundRepo          domain.RefundRepository
 	paymentConfigClient *paymentgateway.PaymentConfigClient
+	notifier            PaymentStatusNotifier
+	breakers            *GatewayBreakers
+	broker              *PaymentStatusBroker
 	defaultPG           string
 }
 
-func NewPaymentUseCase(stripeClient *paymentgateway.StripeClient, xenditClient *paymentgateway.XenditClient, dokuClient *paymentgateway.DokuClient, paymentRepo domain.PaymentRepository, paymentConfigClient *paymentgateway.PaymentConfigClient) PaymentUseCase {
+func NewPaymentUseCase(gateways *GatewayRegistry, paymentRepo domain.PaymentRepository, refundRepo domain.RefundRepository, paymentConfigClient *paymentgateway.PaymentConfigClient, notifier PaymentStatusNotifier, breakers *GatewayBreakers) PaymentUseCase {
 	defaultPG := os.Getenv("DEFAULT_PG")
 	return &paymentUseCase{
-		stripeClient:        stripeClient,
-		xenditClient:        xenditClient,
-		dokuClient:          dokuClient,
+		gateways:            gateways,
 		paymentRepo:         paymentRepo,
+		refundRepo:          refundRepo,
 		paymentConfigClient: paymentConfigClient,
+		notifier:            notifier,
+		breakers:            breakers,
+		broker:              NewPaymentStatusBroker(),
 		defaultPG:           defaultPG,
 	}
 }
 
 func (uc *paymentUseCase) ProcessPayment(ctx context.Context, payment *domain.Payment) (*domain.Payment, error) {
-	var paymentID string
+	var gatewayReference string
 	var err error
 
-	gateway, fallbackGateway, err := uc.paymentConfigClient.GetPaymentGatewayConfig(payment.PaymentMethod)
-	if err != nil || gateway == "" {
-		gateway = uc.defaultPG
+	if err := validatePayment(payment); err != nil {
+		return nil, err
 	}
 
-	payment.Gateway = gateway
-	paymentMethod := payment.PaymentMethod
+	if payment.ExternalID == "" {
+		payment.ExternalID = payment.PaymentID
+	}
 
-	switch gateway {
-	case "XENDIT", "Xendit", "xendit":
-		paymentID, err = uc.processWithXendit(ctx, paymentMethod, payment)
-	case "DOKU", "Doku", "doku":
-		paymentID, err = uc.processWithDoku(ctx, paymentMethod, payment)
-	case "STRIPE", "Stripe", "stripe":
-		paymentID, err = uc.stripeClient.ProcessPayment(ctx, payment)
-	default:
-		return nil, errors.New("unsupported payment gateway")
+	if payment.IdempotencyKey == "" {
+		payment.IdempotencyKey = payment.DefaultIdempotencyKey()
 	}
+	payment.RequestHash = payment.Fingerprint()
 
-	if err != nil && fallbackGateway != "" {
-		payment.Gateway = fallbackGateway
-		switch fallbackGateway {
-		case "XENDIT", "Xendit", "xendit":
-			paymentID, err = uc.processWithXendit(ctx, paymentMethod, payment)
-		case "DOKU", "Doku", "doku":
-			paymentID, err = uc.processWithDoku(ctx, paymentMethod, payment)
-		case "STRIPE", "Stripe", "stripe":
-			paymentID, err = uc.stripeClient.ProcessPayment(ctx, payment)
-		}
+	existing, err := uc.paymentRepo.FindByIdempotencyKey(ctx, payment.IdempotencyKey)
+	if err == nil {
+		return replayPayment(existing, payment)
+	}
+	if !errors.Is(err, domain.ErrPaymentNotFound) {
+		return nil, err
+	}
+
+	gateways, err := uc.paymentConfigClient.GetPaymentGatewayConfig(ctx, payment.PaymentMethod)
+	if err != nil || len(gateways) == 0 {
+		gateways = []string{uc.defaultPG}
+	}
+	// Skip gateways whose circuit is open rather than wait for them to time out.
+	gateways = uc.availableGateways(gateways, payment)
+	if len(gateways) == 0 {
+		return nil, fmt.Errorf("%w: circuits of every gateway for %s open", domain.ErrGatewayUnavailable, payment.PaymentMethod)
 	}
 
+	// Record the payment before the gateway creates it, so that a payment live
+	// at the gateway always has a record. One left initiated, because the
+	// gateway call failed or we did not get to submit it, is resolved by
+	// RecoverInitiatedPayments.
+	payment.Gateway = NormalizeGatewayCode(gateways[0])
+	payment.Status = domain.PaymentStatusInitiated
+	err = uc.paymentRepo.Save(ctx, payment)
+	if errors.Is(err, domain.ErrDuplicatePayment) {
+		// A concurrent request with the same key won the race; answer with its payment.
+		existing, err := uc.paymentRepo.FindByIdempotencyKey(ctx, payment.IdempotencyKey)
+		if err != nil {
+			return nil, err
+		}
+		return replayPayment(existing, payment)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	payment.PaymentID = paymentID
-	payment.Status = "pending"
-	err = uc.paymentRepo.Save(ctx, payment)
+	// A terminal error, such as a decline, would fail at every gateway, and
+	// after an ambiguous one the payment may exist at the gateway already.
+	for _, gateway := range gateways {
+		// Webhooks and RecoverInitiatedPayments go by the stored gateway, so it
+		// must be the one called before the call can create the payment there.
+		if code := NormalizeGatewayCode(gateway); code != payment.Gateway {
+			payment.Gateway = code
+			if err := uc.paymentRepo.RecordAttempts(ctx, payment); err != nil {
+				return nil, err
+			}
+		}
+		gatewayReference, err = uc.processWithGateway(ctx, gateway, payment)
+		if err == nil || !domain.IsRetryableGatewayError(err) {
+			break
+		}
+	}
+
 	if err != nil {
+		return nil, uc.rejectPayment(ctx, payment, err)
+	}
+
+	payment.GatewayReference = gatewayReference
+	if err := uc.paymentRepo.Submit(ctx, payment); err != nil {
 		return nil, err
 	}
+	payment.Status = domain.PaymentStatusPending
 
 	return payment, nil
 }
 
-func (uc *paymentUseCase) processWithXendit(ctx context.Context, paymentMethod string, payment *domain.Payment) (string, error) {
-	switch paymentMethod {
-	case "OVO", "DANA", "LINKAJA":
-		return uc.xenditClient.ChargeEWallet(ctx, payment)
-	case "BCA", "BNI", "BRI":
-		return uc.xenditClient.CreateVirtualAccount(ctx, payment)
-	case "QR":
-		return uc.xenditClient.CreateQRCode(ctx, payment)
-	case "DEFAULT":
-		return uc.xenditClient.ProcessPayment(ctx, payment)
-	default:
-		return "", errors.New("unsupported payment method for Xendit")
+// replayPayment returns the stored payment for a retried request, or
+// ErrIdempotencyConflict if the idempotency key was reused for a different payload.
+func replayPayment(existing, request *domain.Payment) (*domain.Payment, error) {
+	if existing.RequestHash != request.RequestHash {
+		return nil, domain.ErrIdempotencyConflict
 	}
+	if existing.Status == domain.PaymentStatusInitiated {
+		return nil, domain.ErrPaymentInitiated
+	}
+	return existing, nil
 }
 
-func (uc *paymentUseCase) processWithDoku(ctx context.Context, paymentMethod string, payment *domain.Payment) (string, error) {
-	switch paymentMethod {
-	case "OVO", "DANA", "LINKAJA":
-		return uc.dokuClient.ChargeEWallet(ctx, payment)
-	case "BCA", "BNI", "BRI":
-		return uc.dokuClient.CreateVirtualAccount(ctx, payment)
-	case "QR":
-		return uc.dokuClient.CreateQRCode(ctx, payment)
-	case "DEFAULT":
-		return uc.dokuClient.ProcessPayment(ctx, payment)
-	default:
-		return "", errors.New("unsupported payment method for Doku")
+// availableGateways returns the gateways whose circuit lets payment through,
+// recording the others as skipped attempts.
+func (uc *paymentUseCase) availableGateways(gateways []string, payment *domain.Payment) []string {
+	var available []string
+	for _, gateway := range gateways {
+		if uc.breakers.Available(gateway, payment.PaymentMethod) {
+			available = append(available, gateway)
+			continue
+		}
+		log.Printf("Skipping %s for %s payment %s: circuit open", NormalizeGatewayCode(gateway), payment.PaymentMethod, payment.PaymentID)
+		payment.Attempts = append(payment.Attempts, domain.PaymentAttempt{
+			Gateway:     NormalizeGatewayCode(gateway),
+			Error:       domain.ErrGatewayUnavailable.Error(),
+			Retryable:   true,
+			AttemptedAt: time.Now(),
+		})
 	}
+	return available
 }
 
-func (uc *paymentUseCase) RefundPayment(ctx context.Context, paymentID string, amount float64) (string, error) {
-	// Retrieve payment to determine which client to use for refund
+// processWithGateway creates payment at the gateway registered under
+// gatewayCode and records the attempt on it.
+func (uc *paymentUseCase) processWithGateway(ctx context.Context, gatewayCode string, payment *domain.Payment) (string, error) {
+	attempt := domain.PaymentAttempt{Gateway: NormalizeGatewayCode(gatewayCode), AttemptedAt: time.Now()}
+	gatewayReference, err := uc.callGateway(ctx, gatewayCode, payment)
+	attempt.Latency = time.Since(attempt.AttemptedAt)
+	attempt.Succeeded = err == nil
+	if err != nil {
+		log.Printf("Error processing payment %s with %s: %v", payment.PaymentID, attempt.Gateway, err)
+		attempt.Error = err.Error()
+		attempt.Retryable = domain.IsRetryableGatewayError(err)
+	}
+	payment.Attempts = append(payment.Attempts, attempt)
+	return gatewayReference, err
+}
+
+func (uc *paymentUseCase) callGateway(ctx context.Context, gatewayCode string, payment *domain.Payment) (string, error) {
+	gateway, err := uc.gateways.Resolve(gatewayCode, payment.PaymentMethod)
+	if err != nil {
+		// The next gateway may support the method.
+		return "", &domain.GatewayError{Gateway: NormalizeGatewayCode(gatewayCode), Retryable: true, Err: err}
+	}
+	done, err := uc.breakers.Acquire(gatewayCode, payment.PaymentMethod)
+	if err != nil {
+		return "", &domain.GatewayError{Gateway: NormalizeGatewayCode(gatewayCode), Retryable: true, Err: err}
+	}
+	gatewayReference, err := gateway.ProcessPayment(ctx, payment)
+	done(err)
+	return gatewayReference, err
+}
+
+// rejectPayment records the attempts of a payment no gateway accepted and
+// returns cause. A payment the last gateway may have created despite failing,
+// e.g. on a timeout, is left initiated for RecoverInitiatedPayments to resolve;
+// others are failed.
+func (uc *paymentUseCase) rejectPayment(ctx context.Context, payment *domain.Payment, cause error) error {
+	if domain.IsAmbiguousGatewayError(cause) {
+		if err := uc.paymentRepo.RecordAttempts(ctx, payment); err != nil {
+			log.Printf("Error recording attempts of payment %s: %v", payment.PaymentID, err)
+		}
+		return cause
+	}
+
+	if err := uc.paymentRepo.Reject(ctx, payment); err != nil {
+		log.Printf("Error failing payment %s: %v", payment.PaymentID, err)
+		return cause
+	}
+	uc.statusChanged(ctx, payment, domain.PaymentStatusFailed)
+	return cause
+}
+
+// RefundPayment refunds amount of a captured payment and records it in the
+// refund ledger. A zero amount refunds whatever has not been refunded yet, and
+// an amount without a currency is taken to be in the payment's currency.
+// It returns the refund and the payment's status after it. A refund the gateway
+// has yet to settle is left pending, and completed by ApplyRefundNotification.
+func (uc *paymentUseCase) RefundPayment(ctx context.Context, paymentID string, amount domain.Money, reason string) (*domain.Refund, domain.PaymentStatus, error) {
+	// Retrieve payment to determine which gateway to refund through
 	payment, err := uc.paymentRepo.FindByID(ctx, paymentID)
 	if err != nil {
-		return "", err
+		return nil, "", err
 	}
 
-	var refundID string
-	switch payment.PaymentMethod {
-	case "OVO", "DANA", "LINKAJA", "BCA", "BNI", "BRI", "QR", "DEFAULT":
-		refundID, err = uc.xenditClient.RefundPayment(ctx, paymentID, amount)
-	case "STP-DEFAULT":
-		refundID, err = uc.stripeClient.RefundPayment(ctx, paymentID, amount)
-	default:
-		return "", errors.New("unsupported payment method")
+	if amount.Currency == "" {
+		amount.Currency = payment.Amount.Currency
+	}
+	if !strings.EqualFold(amount.Currency, payment.Amount.Currency) {
+		return nil, "", fmt.Errorf("%w: refund in %s for a payment in %s", domain.ErrCurrencyMismatch, amount.Currency, payment.Amount.Currency)
+	}
+	if amount.IsZero() {
+		refundedAmount := payment.RefundedAmount
+		refundedAmount.Currency = payment.Amount.Currency
+		amount, err = payment.Amount.Sub(refundedAmount)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if amount.IsNegative() || amount.IsZero() {
+		return nil, "", domain.ErrInvalidRefundAmount
+	}
+
+	// Either status may follow, depending on how much is left after the refund.
+	if !payment.Status.CanTransitionTo(domain.PaymentStatusPartiallyRefunded) || !payment.Status.CanTransitionTo(domain.PaymentStatusRefunded) {
+		return nil, "", &domain.StatusTransitionError{From: payment.Status, To: domain.PaymentStatusRefunded}
 	}
 
+	// Refund through the gateway that captured the payment, which may be a
+	// fallback rather than the usual gateway for the payment method.
+	gateway, err := uc.gateways.Get(payment.Gateway)
 	if err != nil {
-		return "", err
+		return nil, "", fmt.Errorf("refund payment %s: %w", paymentID, err)
 	}
 
-	err = uc.paymentRepo.UpdateStatus(ctx, paymentID, "refunded")
+	refund := &domain.Refund{
+		RefundID:  uuid.New().String(),
+		PaymentID: payment.PaymentID,
+		Amount:    amount,
+		Reason:    reason,
+		Gateway:   NormalizeGatewayCode(payment.Gateway),
+		Status:    domain.RefundStatusPending,
+	}
+	if err := uc.refundRepo.Save(ctx, refund); err != nil {
+		return nil, "", err
+	}
+
+	// Reserve the amount before calling the gateway so concurrent refunds
+	// cannot together exceed the captured amount.
+	_, err = uc.paymentRepo.ReserveRefund(ctx, payment.PaymentID, amount)
+	if err != nil {
+		uc.failRefund(ctx, refund, err)
+		return nil, "", err
+	}
+
+	gatewayRefund, err := gateway.RefundPayment(ctx, payment, amount)
 	if err != nil {
-		return "", err
+		uc.releaseRefund(ctx, payment, amount)
+		uc.failRefund(ctx, refund, err)
+		return nil, "", fmt.Errorf("refund payment %s via %s: %w", paymentID, refund.Gateway, err)
+	}
+	refund.GatewayRefundID = gatewayRefund.GatewayRefundID
+
+	switch gatewayRefund.Status {
+	case domain.RefundStatusSucceeded:
+		// Concurrent refunds finish in any order, so the payment's status follows
+		// the stored total refunded rather than the amount this refund reserved.
+		updated, err := uc.paymentRepo.CompleteRefund(ctx, refund)
+		if err != nil {
+			return nil, "", err
+		}
+		uc.refundStatusChanged(ctx, payment, updated)
+		return refund, updated.Status, nil
+	case domain.RefundStatusFailed:
+		uc.releaseRefund(ctx, payment, amount)
+		uc.failRefund(ctx, refund, fmt.Errorf("refund %s failed at %s", refund.GatewayRefundID, refund.Gateway))
+		return refund, payment.Status, nil
+	default:
+		// The amount stays reserved until ApplyRefundNotification records the
+		// gateway's outcome.
+		if err := uc.refundRepo.Update(ctx, refund); err != nil {
+			return nil, "", err
+		}
+		return refund, payment.Status, nil
+	}
+}
+
+// applyRefundStatus moves payment to the status the total refunded calls for.
+func (uc *paymentUseCase) applyRefundStatus(ctx context.Context, payment *domain.Payment) error {
+	updated, err := uc.paymentRepo.UpdateRefundStatus(ctx, payment.PaymentID)
+	if err != nil {
+		return err
+	}
+	uc.refundStatusChanged(ctx, payment, updated)
+	return nil
+}
+
+// refundStatusChanged tells the notifier if a refund moved payment to the
+// status of updated.
+func (uc *paymentUseCase) refundStatusChanged(ctx context.Context, payment, updated *domain.Payment) {
+	payment.RefundedAmount = updated.RefundedAmount
+	if updated.Status != payment.Status {
+		uc.statusChanged(ctx, payment, updated.Status)
+	}
+}
+
+// releaseRefund gives back the amount reserved for a refund of payment that
+// did not go through, and moves the payment back to the status the amount
+// still refunded calls for.
+func (uc *paymentUseCase) releaseRefund(ctx context.Context, payment *domain.Payment, amount domain.Money) {
+	if err := uc.paymentRepo.ReleaseRefund(ctx, payment.PaymentID, amount); err != nil {
+		log.Printf("Error releasing refund reservation of payment %s: %v", payment.PaymentID, err)
+		return
+	}
+	if err := uc.applyRefundStatus(ctx, payment); err != nil {
+		log.Printf("Error updating refund status of payment %s: %v", payment.PaymentID, err)
+	}
+}
+
+// statusChanged tells the notifier that payment moved to status.
+func (uc *paymentUseCase) statusChanged(ctx context.Context, payment *domain.Payment, status domain.PaymentStatus) {
+	previous := payment.Status
+	payment.Status = status
+	payment.UpdatedAt = time.Now()
+	uc.broker.PaymentStatusChanged(ctx, payment, previous)
+	if uc.notifier != nil {
+		uc.notifier.PaymentStatusChanged(ctx, payment, previous)
+	}
+}
+
+// failRefund records in the ledger that a refund did not go through.
+func (uc *paymentUseCase) failRefund(ctx context.Context, refund *domain.Refund, cause error) {
+	refund.Status = domain.RefundStatusFailed
+	refund.FailureReason = cause.Error()
+	if err := uc.refundRepo.Update(ctx, refund); err != nil {
+		log.Printf("Error marking refund %s as failed: %v", refund.RefundID, err)
 	}
+}
 
-	return refundID, nil
+func (uc *paymentUseCase) ListRefunds(ctx context.Context, paymentID string) ([]domain.Refund, error) {
+	if _, err := uc.paymentRepo.FindByID(ctx, paymentID); err != nil {
+		return nil, err
+	}
+	return uc.refundRepo.FindByPaymentID(ctx, paymentID)
 }
 
 func (uc *paymentUseCase) GetPayment(ctx context.Context, paymentID string) (*domain.Payment, error) {
@@ -161,17 +397,328 @@ func (uc *paymentUseCase) ListPayments(ctx context.Context, userID string, page,
 	return payments, total, nil
 }
 
-func (uc *paymentUseCase) QrWebhook(ctx context.Context, requestBody domain.XenditWebhookRequestPaymentData) (string, error) {
-	payment, err := uc.paymentRepo.FindByID(ctx, requestBody.ReferenceID)
+func (uc *paymentUseCase) CancelPayment(ctx context.Context, paymentID, reason string) (*domain.Payment, error) {
+	payment, err := uc.paymentRepo.FindByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if !payment.Status.CanTransitionTo(domain.PaymentStatusCancelled) {
+		return nil, &domain.StatusTransitionError{From: payment.Status, To: domain.PaymentStatusCancelled}
+	}
+
+	gateway, err := uc.gateways.Get(payment.Gateway)
+	if err != nil {
+		return nil, fmt.Errorf("cancel payment %s: %w", paymentID, err)
+	}
+	canceller, ok := gateway.(domain.PaymentCanceller)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s payments", domain.ErrCancelNotSupported, NormalizeGatewayCode(payment.Gateway))
+	}
+	// Cancel at the gateway first: a payment it can still take must not be
+	// reported as cancelled.
+	if err := canceller.CancelPayment(ctx, payment); err != nil {
+		return nil, fmt.Errorf("cancel payment %s via %s: %w", paymentID, NormalizeGatewayCode(payment.Gateway), err)
+	}
+
+	if err := uc.paymentRepo.Cancel(ctx, payment.PaymentID, reason); err != nil {
+		return nil, err
+	}
+	payment.CancellationReason = reason
+	uc.statusChanged(ctx, payment, domain.PaymentStatusCancelled)
+	return payment, nil
+}
+
+func (uc *paymentUseCase) ExpireOverduePayments(ctx context.Context, limit int) (int, error) {
+	payments, err := uc.paymentRepo.FindExpired(ctx, time.Now(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range payments {
+		payment := &payments[i]
+		if err := uc.expireAtGateway(ctx, payment); err != nil {
+			// Left pending, so the next sweep tries again.
+			log.Printf("Error expiring payment %s at %s: %v", payment.PaymentID, payment.Gateway, err)
+			continue
+		}
+
+		err := uc.paymentRepo.UpdateStatus(ctx, payment.PaymentID, domain.PaymentStatusExpired)
+		if errors.Is(err, domain.ErrInvalidStatusTransition) {
+			// Paid or failed since it was read.
+			continue
+		}
+		if err != nil {
+			log.Printf("Error marking payment %s as expired: %v", payment.PaymentID, err)
+			continue
+		}
+		uc.statusChanged(ctx, payment, domain.PaymentStatusExpired)
+		expired++
+	}
+	return expired, nil
+}
+
+// expireAtGateway deactivates payment at its gateway, if the gateway can.
+func (uc *paymentUseCase) expireAtGateway(ctx context.Context, payment *domain.Payment) error {
+	gateway, err := uc.gateways.Get(payment.Gateway)
+	if err != nil {
+		return err
+	}
+	expirer, ok := gateway.(domain.PaymentExpirer)
+	if !ok {
+		return nil
+	}
+	err = expirer.ExpirePayment(ctx, payment)
+	if errors.Is(err, domain.ErrExpiryNotSupported) {
+		return nil
+	}
+	return err
+}
+
+func (uc *paymentUseCase) RecoverInitiatedPayments(ctx context.Context, olderThan time.Duration, limit int) (int, error) {
+	payments, err := uc.paymentRepo.FindInitiated(ctx, time.Now().Add(-olderThan), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	recovered := 0
+	for i := range payments {
+		payment := &payments[i]
+		if err := uc.recoverPayment(ctx, payment); err != nil {
+			log.Printf("Error recovering initiated payment %s: %v", payment.PaymentID, err)
+			continue
+		}
+		recovered++
+	}
+	return recovered, nil
+}
+
+// recoverPayment submits an initiated payment the gateway created, applying
+// the status the gateway holds for it, and fails one it did not create. A
+// payment the gateway cannot look up may still be live there, so it is left
+// initiated and flagged for reconciliation instead.
+func (uc *paymentUseCase) recoverPayment(ctx context.Context, payment *domain.Payment) error {
+	transaction, err := uc.findGatewayTransaction(ctx, payment)
+	switch {
+	case errors.Is(err, domain.ErrGatewayTransactionNotFound):
+		log.Printf("Failing initiated payment %s: %v", payment.PaymentID, err)
+		if err := uc.paymentRepo.UpdateStatus(ctx, payment.PaymentID, domain.PaymentStatusFailed); err != nil {
+			return err
+		}
+		uc.statusChanged(ctx, payment, domain.PaymentStatusFailed)
+		return nil
+	case errors.Is(err, domain.ErrUnsupportedGateway),
+		errors.Is(err, domain.ErrTransactionLookupNotSupported):
+		log.Printf("Flagging initiated payment %s for reconciliation: %v", payment.PaymentID, err)
+		return uc.paymentRepo.FlagForReconciliation(ctx, payment.PaymentID)
+	case err != nil:
+		return err
+	}
+
+	payment.GatewayReference = transaction.GatewayReference
+	payment.ExpiresAt = transaction.ExpiresAt
+	if err := uc.paymentRepo.Submit(ctx, payment); err != nil {
+		return err
+	}
+	payment.Status = domain.PaymentStatusPending
+	log.Printf("Recovered initiated payment %s as %s %s", payment.PaymentID, payment.Gateway, payment.GatewayReference)
+
+	if transaction.Status == domain.PaymentStatusPending {
+		return nil
+	}
+	_, err = uc.ApplyPaymentNotification(ctx, domain.PaymentNotification{
+		Gateway:          payment.Gateway,
+		ExternalID:       payment.ExternalID,
+		GatewayReference: payment.GatewayReference,
+		Status:           transaction.Status,
+	})
+	return err
+}
+
+// findGatewayTransaction asks the gateway of payment for the payment it created
+// under payment.ExternalID.
+func (uc *paymentUseCase) findGatewayTransaction(ctx context.Context, payment *domain.Payment) (*domain.GatewayTransaction, error) {
+	gateway, err := uc.gateways.Get(payment.Gateway)
+	if err != nil {
+		return nil, err
+	}
+	finder, ok := gateway.(domain.TransactionFinder)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s payments", domain.ErrTransactionLookupNotSupported, NormalizeGatewayCode(payment.Gateway))
+	}
+	return finder.FindTransaction(ctx, payment)
+}
+
+// watchRefreshInterval is how often WatchPaymentStatus re-reads a watched
+// payment, catching changes applied by other instances of the service.
+const watchRefreshInterval = 5 * time.Second
+
+func (uc *paymentUseCase) WatchPaymentStatus(ctx context.Context, paymentID string, send func(*domain.Payment) error) error {
+	// Subscribe before reading the payment so that no change falls in between.
+	updates, unsubscribe := uc.broker.Subscribe(paymentID)
+	defer unsubscribe()
+
+	payment, err := uc.paymentRepo.FindByID(ctx, paymentID)
+	if err != nil {
+		return err
+	}
+	if err := send(payment); err != nil {
+		return err
+	}
+
+	refresh := time.NewTicker(watchRefreshInterval)
+	defer refresh.Stop()
+	last := payment.Status
+	for !watchFinished(last) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case update := <-updates:
+			payment = &update
+		case <-refresh.C:
+			payment, err = uc.paymentRepo.FindByID(ctx, paymentID)
+			if err != nil {
+				return err
+			}
+		}
+		if payment.Status == last {
+			continue
+		}
+		if err := send(payment); err != nil {
+			return err
+		}
+		last = payment.Status
+	}
+	return nil
+}
+
+// watchFinished reports whether a watcher has seen the last status it cares
+// about: the outcome of the payment, or a status nothing can follow.
+func watchFinished(status domain.PaymentStatus) bool {
+	return status.IsSettlement() || status.IsTerminal()
+}
+
+// ApplyPaymentNotification moves a payment to the status a gateway reported
+// for it.
+func (uc *paymentUseCase) ApplyPaymentNotification(ctx context.Context, notification domain.PaymentNotification) (string, error) {
+	payment, err := uc.findNotifiedPayment(ctx, notification)
+	if err != nil {
+		return "failed", err
+	}
+	// Refunds go through payment.Gateway, so it must be the gateway that took the payment.
+	if payment.Gateway != "" && NormalizeGatewayCode(notification.Gateway) != NormalizeGatewayCode(payment.Gateway) {
+		log.Printf("Payment %s made through %s notified by %s", payment.PaymentID, payment.Gateway, notification.Gateway)
+		return "failed", fmt.Errorf("%w: payment %s made through %s notified by %s", domain.ErrGatewayMismatch, payment.PaymentID, NormalizeGatewayCode(payment.Gateway), NormalizeGatewayCode(notification.Gateway))
+	}
+
+	// A payment left initiated was created at the gateway after all.
+	if payment.Status == domain.PaymentStatusInitiated {
+		if notification.GatewayReference != "" {
+			payment.GatewayReference = notification.GatewayReference
+		}
+		if err := uc.paymentRepo.Submit(ctx, payment); err != nil {
+			return "failed", err
+		}
+		log.Printf("Submitted initiated payment %s notified by %s", payment.PaymentID, payment.Gateway)
+		payment.Status = domain.PaymentStatusPending
+	}
+
+	// Gateways retry callbacks, so a repeat of the status we already hold is not
+	// an error, nor is a gateway reporting a payment we cancelled as failed.
+	if payment.Status == notification.Status ||
+		(payment.Status == domain.PaymentStatusCancelled && notification.Status == domain.PaymentStatusFailed) {
+		return "Success", nil
+	}
+	// Gateways report whether a payment was made only while it is pending; a late
+	// report must not undo a refund or dispute. A payment we expired may still be
+	// paid at a gateway that could not deactivate it.
+	stillPayable := payment.Status == domain.PaymentStatusPending ||
+		(payment.Status == domain.PaymentStatusExpired && notification.Status == domain.PaymentStatusPaid)
+	if notification.Status.IsSettlement() && !stillPayable {
+		return "failed", &domain.StatusTransitionError{From: payment.Status, To: notification.Status}
+	}
+
+	// QR refunds are issued against the QR payment, not the QR code we created.
+	if notification.Status == domain.PaymentStatusPaid && notification.QrPaymentID != "" {
+		err = uc.paymentRepo.UpdateQrPaymentID(ctx, payment.PaymentID, notification.QrPaymentID)
+		if err != nil {
+			return "failed", err
+		}
+	}
+
+	err = uc.paymentRepo.UpdateStatus(ctx, payment.PaymentID, notification.Status)
+	if err != nil {
+		return "failed", err
+	}
+	uc.statusChanged(ctx, payment, notification.Status)
+
+	return "Success", nil
+}
+
+// findNotifiedPayment looks up the payment a gateway notification is about.
+func (uc *paymentUseCase) findNotifiedPayment(ctx context.Context, notification domain.PaymentNotification) (*domain.Payment, error) {
+	if notification.ExternalID != "" {
+		payment, err := uc.paymentRepo.FindByExternalID(ctx, notification.ExternalID)
+		if !errors.Is(err, domain.ErrPaymentNotFound) || notification.GatewayReference == "" {
+			return payment, err
+		}
+	}
+	if notification.GatewayReference == "" {
+		return nil, domain.ErrPaymentNotFound
+	}
+	// Payments migrated from before external IDs were stored are only known by
+	// the gateway's ID, and some notifications carry nothing else.
+	return uc.paymentRepo.FindByGatewayReference(ctx, notification.GatewayReference)
+}
+
+// ApplyRefundNotification records the outcome a gateway reported for a refund.
+// A refund that fails after the gateway accepted it gives its amount back to
+// the payment.
+func (uc *paymentUseCase) ApplyRefundNotification(ctx context.Context, notification domain.RefundNotification) (string, error) {
+	refund, err := uc.refundRepo.FindByGatewayRefundID(ctx, NormalizeGatewayCode(notification.Gateway), notification.GatewayRefundID)
 	if err != nil {
 		return "failed", err
 	}
+	if refund.Status == notification.Status {
+		return "Success", nil
+	}
 
-	err = uc.paymentRepo.UpdateStatus(ctx, payment.PaymentID, requestBody.Status)
+	if notification.Status == domain.RefundStatusSucceeded {
+		payment, err := uc.paymentRepo.FindByID(ctx, refund.PaymentID)
+		if err != nil {
+			return "failed", err
+		}
+		updated, err := uc.paymentRepo.CompleteRefund(ctx, refund)
+		if err != nil {
+			return "failed", err
+		}
+		uc.refundStatusChanged(ctx, payment, updated)
+		return "Success", nil
+	}
+	if notification.Status != domain.RefundStatusFailed {
+		refund.Status = notification.Status
+		if err := uc.refundRepo.Update(ctx, refund); err != nil {
+			return "failed", err
+		}
+		return "Success", nil
+	}
 
+	refund.Status = domain.RefundStatusFailed
+	refund.FailureReason = notification.FailureReason
+	if err := uc.refundRepo.Update(ctx, refund); err != nil {
+		return "failed", err
+	}
+	if err := uc.paymentRepo.ReleaseRefund(ctx, refund.PaymentID, refund.Amount); err != nil {
+		return "failed", err
+	}
+
+	payment, err := uc.paymentRepo.FindByID(ctx, refund.PaymentID)
 	if err != nil {
 		return "failed", err
 	}
+	if err := uc.applyRefundStatus(ctx, payment); err != nil {
+		return "failed", err
+	}
 
 	return "Success", nil
 }
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"fmt"
+	"payment-service/internal/domain"
+	"strings"
+)
+
+// validatePayment checks that a payment request is internally consistent
+// before it reaches a gateway: line items, tax, shipping and discount must be
+// non-negative, share the payment's currency, and add up to the amount.
+// Field names follow ProcessPaymentRequest.
+func validatePayment(payment *domain.Payment) error {
+	violations := &domain.ValidationError{}
+	currency := payment.Amount.Currency
+
+	if payment.Amount.IsNegative() || payment.Amount.IsZero() {
+		violations.Add("amount_minor", "must be positive")
+	}
+
+	// The subtotal is only meaningful if every line could be added up.
+	consistent := true
+	sameCurrency := func(field string, amount domain.Money) bool {
+		if !strings.EqualFold(amount.Currency, currency) {
+			violations.Add(field, "currency %s does not match payment currency %s", amount.Currency, currency)
+			consistent = false
+			return false
+		}
+		return true
+	}
+
+	subtotal := domain.Money{Currency: currency}
+	for i, item := range payment.Items {
+		field := fmt.Sprintf("items[%d]", i)
+		if item.Quantity <= 0 {
+			violations.Add(field+".quantity", "must be positive")
+			consistent = false
+		}
+		if item.Price.IsNegative() {
+			violations.Add(field+".price_minor", "must not be negative")
+			consistent = false
+		}
+		if sameCurrency(field+".price_minor", item.Price) {
+			subtotal.MinorUnits += item.Price.MinorUnits * int64(item.Quantity)
+		}
+	}
+
+	total := subtotal
+	for _, line := range []struct {
+		field  string
+		amount domain.Money
+		sign   int64
+	}{
+		{"tax_minor", payment.Tax, 1},
+		{"shipping_minor", payment.Shipping, 1},
+		{"discount_minor", payment.Discount, -1},
+	} {
+		if line.amount.IsZero() {
+			continue
+		}
+		if line.amount.IsNegative() {
+			violations.Add(line.field, "must not be negative")
+			consistent = false
+		}
+		if sameCurrency(line.field, line.amount) {
+			total.MinorUnits += line.sign * line.amount.MinorUnits
+		}
+	}
+
+	if consistent && total.MinorUnits != payment.Amount.MinorUnits {
+		violations.Add("amount_minor", "must equal items plus tax and shipping minus discount (%s), got %s", total, payment.Amount)
+	}
+
+	return violations.ErrOrNil()
+}
@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"payment-service/internal/domain"
+	"time"
+)
+
+// WebhookInbox records every webhook received from a gateway and makes sure
+// each event is applied once: a redelivery of an event that was already
+// processed is acknowledged with the original result, while one that failed is
+// processed again. Deliveries claim an event before processing it, so only one
+// of several concurrent deliveries applies it.
+type WebhookInbox struct {
+	events domain.WebhookEventRepository
+}
+
+// webhookEventLease is how long a delivery holds an event it is processing
+// before a redelivery may take it over.
+const webhookEventLease = 2 * time.Minute
+
+func NewWebhookInbox(events domain.WebhookEventRepository) *WebhookInbox {
+	return &WebhookInbox{events: events}
+}
+
+// Handle stores event and runs process for it unless it was processed before.
+// It returns the processing result and whether event was a replay, or
+// ErrWebhookEventInProgress while another delivery is processing the event.
+func (i *WebhookInbox) Handle(ctx context.Context, event *domain.WebhookEvent, process func(ctx context.Context) (string, error)) (string, bool, error) {
+	now := time.Now()
+	event.Status = domain.WebhookEventStatusProcessing
+	event.LeaseUntil = now.Add(webhookEventLease)
+	event.Attempts = 1
+
+	err := i.events.Save(ctx, event)
+	if errors.Is(err, domain.ErrDuplicateWebhookEvent) {
+		claimed, claimErr := i.events.Claim(ctx, event.Provider, event.WebhookID, now, webhookEventLease)
+		if errors.Is(claimErr, domain.ErrWebhookEventInProgress) {
+			existing, findErr := i.events.Find(ctx, event.Provider, event.WebhookID)
+			if findErr != nil {
+				return "", false, findErr
+			}
+			if existing.Status == domain.WebhookEventStatusProcessed {
+				return existing.Result, true, nil
+			}
+			return "", false, claimErr
+		}
+		if claimErr != nil {
+			return "", false, claimErr
+		}
+		event = claimed
+	} else if err != nil {
+		return "", false, err
+	}
+
+	result, err := process(ctx)
+	if err != nil {
+		event.Status = domain.WebhookEventStatusFailed
+		event.Error = err.Error()
+	} else {
+		event.Status = domain.WebhookEventStatusProcessed
+		event.Result = result
+		event.Error = ""
+		event.ProcessedAt = time.Now()
+	}
+	if updateErr := i.events.Update(ctx, event); updateErr != nil {
+		log.Printf("failed to record outcome of %s webhook %s: %v", event.Provider, event.WebhookID, updateErr)
+	}
+	return result, false, err
+}